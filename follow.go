@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"solid-system/race"
+)
+
+var followPollInterval = 500 * time.Millisecond
+
+// followEvents tails the events file at path like `tail -f`: every complete
+// line is parsed and applied to run as soon as it is written, its log
+// entries are printed to w, and the standings are reprinted whenever the
+// reader catches up with the writer. It returns once ctx is cancelled.
+func followEvents(ctx context.Context, path string, run *raceRun, config *race.Config, w io.Writer) error {
+	eventsLogFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening events file: %w", err)
+	}
+	defer eventsLogFile.Close()
+
+	reader := bufio.NewReader(eventsLogFile)
+	lineNumber := 0
+	pending := ""
+	applied := false
+
+	handleLine := func(line string) {
+		lineNumber++
+		event, err := race.ParseEvent(line)
+		if err != nil {
+			run.parseError(lineNumber, line, err)
+			return
+		}
+		if event == nil {
+			return
+		}
+		event.Line = lineNumber
+		for _, logEntry := range run.apply(event) {
+			fmt.Fprintln(w, logEntry)
+		}
+		applied = true
+	}
+
+	for {
+		chunk, err := reader.ReadString('\n')
+		pending += chunk
+		if err == nil {
+			handleLine(pending)
+			pending = ""
+			continue
+		}
+		if err != io.EOF {
+			return fmt.Errorf("error reading events file: %w", err)
+		}
+
+		// Caught up with the writer; a partial line stays pending until
+		// the rest of it arrives.
+		if applied {
+			printStandings(w, run, config)
+			applied = false
+		}
+
+		select {
+		case <-ctx.Done():
+			if pending != "" {
+				handleLine(pending)
+			}
+			return nil
+		case <-time.After(followPollInterval):
+		}
+	}
+}
+
+// printStandings writes the current standings table to w.
+func printStandings(w io.Writer, run *raceRun, config *race.Config) {
+	fmt.Fprintln(w, "Standings")
+	for _, line := range run.standings(config) {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "End Standings")
+}
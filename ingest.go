@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"solid-system/race"
+)
+
+// ingestCommand receives event lines from timing equipment over TCP or UDP
+// instead of reading an events file, and answers every line with an ack or
+// the problems it caused, until interrupted.
+func ingestCommand(args []string) int {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	network := flags.String("network", "tcp", "receive events over tcp or udp")
+	listen := flags.String("listen", ":9000", "listen for events on this `address`")
+	httpAddr := flags.String("http", "", "also serve the live results over HTTP on this `address`, as the serve command does")
+	statePath := flags.String("state", "", "save the final state to this `file` for the report command")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . ingest [flags] <config>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+	if *network != "tcp" && *network != "udp" {
+		fmt.Printf("unknown network %q\n", *network)
+		flags.Usage()
+		return 1
+	}
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+	run := newRaceRun(config)
+	in := &ingester{run: run, w: os.Stdout}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var server *http.Server
+	// serverFailed is closed if the results server stops with an error,
+	// such as its address being in use, which also ends the ingest.
+	serverFailed := make(chan struct{})
+	if *httpAddr != "" {
+		server = &http.Server{Addr: *httpAddr, Handler: newServer(run)}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("error serving results: %v\n", err)
+				close(serverFailed)
+				stop()
+			}
+		}()
+		fmt.Printf("serving results on %s\n", *httpAddr)
+	}
+
+	var err error
+	if *network == "udp" {
+		err = in.listenUDP(ctx, *listen)
+	} else {
+		err = in.listenTCP(ctx, *listen)
+	}
+	stop()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	exitCode := 0
+	select {
+	case <-serverFailed:
+		exitCode = 1
+	default:
+	}
+
+	for _, logEntry := range run.finish() {
+		fmt.Println(logEntry)
+	}
+	printStandings(os.Stdout, run, config)
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}
+
+	if *statePath != "" {
+		if err := writeState(*statePath, config, run.snapshot()); err != nil {
+			fmt.Printf("error writing state file: %v\n", err)
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// ingester applies event lines received from any number of senders to run,
+// numbering them in the order they arrive.
+type ingester struct {
+	run *raceRun
+	// w receives the log entries of every applied event.
+	w io.Writer
+
+	mu         sync.Mutex
+	lineNumber int
+}
+
+// handleLine parses and applies one received line and returns the reply
+// for its sender: "ok N" with the line's number if the event was applied,
+// followed by " warning " and the problems it was flagged for if any, or
+// "error " followed by every problem the line caused if it was rejected or
+// could not be parsed. Blank lines get no reply.
+func (in *ingester) handleLine(line string) (string, bool) {
+	if strings.TrimSpace(line) == "" {
+		return "", false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.lineNumber++
+
+	event, err := race.ParseEvent(line)
+	if err != nil {
+		v := in.run.parseError(in.lineNumber, line, err)
+		return "error " + v.Error(), true
+	}
+	event.Line = in.lineNumber
+	entries, violations, err := in.run.applyChecked(event)
+	for _, logEntry := range entries {
+		fmt.Fprintln(in.w, logEntry)
+	}
+	problems := make([]string, 0, len(violations))
+	for _, v := range violations {
+		problems = append(problems, v.Error())
+	}
+	if err != nil {
+		return "error " + strings.Join(problems, "; "), true
+	}
+	reply := fmt.Sprintf("ok %d", in.lineNumber)
+	if len(problems) > 0 {
+		reply += " warning " + strings.Join(problems, "; ")
+	}
+	return reply, true
+}
+
+// listenTCP accepts connections on addr and reads event lines from each,
+// writing a reply line back for every one, until ctx is cancelled.
+func (in *ingester) listenTCP(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening for events: %w", err)
+	}
+	fmt.Printf("receiving events on tcp %s\n", listener.Addr())
+	return in.serveTCP(ctx, listener)
+}
+
+func (in *ingester) serveTCP(ctx context.Context, listener net.Listener) error {
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	// Closing the listener and the open connections ends the loops below.
+	var mu sync.Mutex
+	open := map[net.Conn]bool{}
+	stopped := context.AfterFunc(ctx, func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range open {
+			conn.Close()
+		}
+	})
+	defer stopped()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error accepting events connection: %w", err)
+		}
+		mu.Lock()
+		open[conn] = true
+		mu.Unlock()
+		if ctx.Err() != nil {
+			conn.Close()
+		}
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer func() {
+				mu.Lock()
+				delete(open, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if reply, ok := in.handleLine(scanner.Text()); ok {
+					if _, err := fmt.Fprintln(conn, reply); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+}
+
+// listenUDP reads datagrams of one or more event lines on addr and sends
+// the replies to each back in a single datagram, until ctx is cancelled.
+func (in *ingester) listenUDP(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening for events: %w", err)
+	}
+	fmt.Printf("receiving events on udp %s\n", conn.LocalAddr())
+	return in.serveUDP(ctx, conn)
+}
+
+func (in *ingester) serveUDP(ctx context.Context, conn net.PacketConn) error {
+	stopped := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stopped()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, sender, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error receiving events: %w", err)
+		}
+		var replies []string
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if reply, ok := in.handleLine(line); ok {
+				replies = append(replies, reply)
+			}
+		}
+		if len(replies) > 0 {
+			conn.WriteTo([]byte(strings.Join(replies, "\n")+"\n"), sender)
+		}
+	}
+}
@@ -2,720 +2,369 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
-	"strconv"
-	"strings"
-	"time"
-)
-
-const timeLayout = "15:04:05.000" // HH:MM:SS.sss
-const eventTimeLayout = "[" + timeLayout + "]"
-const configTimeLayout = "15:04:05"
+	"sync"
 
-type Config struct {
-	Laps        int     `json:"laps"`
-	LapLen      float64 `json:"lapLen"`
-	PenaltyLen  float64 `json:"penaltyLen"`
-	FiringLines int     `json:"firingLines"`
-	Start       string  `json:"start"`
-	StartDelta  string  `json:"startDelta"`
+	"solid-system/race"
+)
 
-	parsedStart      time.Time
-	parsedStartDelta time.Duration
+// raceRun collects everything a single pass of the engine produces.
+type raceRun struct {
+	config *race.Config
+	// warnings receives a line per rejected or unparsable event.
+	warnings io.Writer
+
+	// mu guards the fields below so that the HTTP server can read them
+	// while events are being applied.
+	mu        sync.Mutex
+	engine    *race.RaceEngine
+	outputLog []race.LogEntry
+	// eventLog holds incoming events as submitted plus the outgoing events
+	// generated by the engine, in a form ParseEvent can read back.
+	eventLog []string
+	// parseErrors holds one violation per events file line that could not
+	// be parsed.
+	parseErrors []race.Violation
+	// logChanged is closed and replaced whenever outputLog grows or the run
+	// finishes.
+	logChanged chan struct{}
+	finished   bool
 }
 
-type Lap struct {
-	Number    int
-	StartTime time.Time
-	EndTime   time.Time
-	Distance  float64
+func newRaceRun(config *race.Config) *raceRun {
+	return &raceRun{
+		config:     config,
+		engine:     race.NewRaceEngine(config),
+		warnings:   os.Stdout,
+		logChanged: make(chan struct{}),
+	}
 }
 
-func (l Lap) Duration() time.Duration {
-	if l.StartTime.IsZero() || l.EndTime.IsZero() {
-		return 0
-	}
-	return l.EndTime.Sub(l.StartTime)
+func (r *raceRun) apply(event *race.Event) []race.LogEntry {
+	entries, _, _ := r.applyChecked(event)
+	return entries
 }
 
-func (l Lap) AverageSpeed() float64 {
-	durationSeconds := l.Duration().Seconds()
-	if durationSeconds <= 0 || l.Distance <= 0 {
-		return 0.0
+// applyChecked applies event like apply and also returns the violations it
+// caused and the engine's error if the event was rejected.
+func (r *raceRun) applyChecked(event *race.Event) ([]race.LogEntry, []race.Violation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := len(r.engine.Violations())
+	entries, err := r.engine.Apply(event)
+	if err != nil {
+		fmt.Fprintf(r.warnings, "Warning: %v\n", err)
+	}
+	r.outputLog = append(r.outputLog, entries...)
+	// Outgoing events read back from an earlier run are regenerated by the
+	// engine, so echoing them as well would log each one twice.
+	if !event.IsOutgoing() {
+		r.eventLog = append(r.eventLog, event.RawLine)
+	}
+	r.appendOutgoing(entries)
+	if len(entries) > 0 {
+		r.notifyLog()
 	}
-	return l.Distance / durationSeconds
+	return entries, r.engine.Violations()[before:], err
 }
 
-type PenaltyLap struct {
-	StartTime time.Time
-	EndTime   time.Time
-	Distance  float64
+func (r *raceRun) parseError(lineNumber int, line string, err error) race.Violation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.warnings, "error parsing event on line %d: %v\n", lineNumber, err)
+	v := race.NewParseViolation(lineNumber, line, err)
+	r.parseErrors = append(r.parseErrors, v)
+	return v
 }
 
-func (p PenaltyLap) Duration() time.Duration {
-	if p.StartTime.IsZero() || p.EndTime.IsZero() {
-		return 0
-	}
-	return p.EndTime.Sub(p.StartTime)
+// violations returns parse errors and the engine's violations ordered by
+// line number.
+func (r *raceRun) violations() []race.Violation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	violations := append(append([]race.Violation(nil), r.parseErrors...), r.engine.Violations()...)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Line < violations[j].Line
+	})
+	return violations
 }
 
-func (p PenaltyLap) AverageSpeed() float64 {
-	durationSeconds := p.Duration().Seconds()
-	if durationSeconds <= 0 || p.Distance <= 0 {
-		return 0.0
+// reportProblems prints every parse error and engine violation to w and
+// returns how many there were.
+func (r *raceRun) reportProblems(w io.Writer) int {
+	violations := r.violations()
+	for _, v := range violations {
+		fmt.Fprintln(w, v.Error())
 	}
-	return p.Distance / durationSeconds
-}
-
-type FiringRangeVisit struct {
-	EnterTime time.Time
-	ExitTime  time.Time
-	Hits      int
-	Shots     int
+	return len(violations)
 }
 
-type CompetitorStatus string
-
-const (
-	StatusRegistered   CompetitorStatus = "Registered"
-	StatusScheduled    CompetitorStatus = "Scheduled"
-	StatusOnStartLine  CompetitorStatus = "OnStartLine"
-	StatusStarted      CompetitorStatus = "Started"
-	StatusOnLap        CompetitorStatus = "OnLap"
-	StatusOnRange      CompetitorStatus = "OnRange"
-	StatusInPenalty    CompetitorStatus = "InPenalty"
-	StatusFinished     CompetitorStatus = "Finished"
-	StatusNotFinished  CompetitorStatus = "NotFinished"
-	StatusDisqualified CompetitorStatus = "Disqualified"
-	StatusNotStarted   CompetitorStatus = "NotStarted"
-)
-
-type Competitor struct {
-	ID                 int
-	Status             CompetitorStatus
-	ScheduledStartTime time.Time
-	ActualStartTime    time.Time
-	FinishTime         time.Time
-	Comment            string
-
-	LapsCompleted    []Lap
-	CurrentLapNumber int
-	CurrentLapStart  time.Time
-
-	PenaltyLapsCompleted []PenaltyLap
-	CurrentPenaltyStart  time.Time
-	CurrentPenaltyDist   float64
-
-	FiringRangeVisits []FiringRangeVisit
-	CurrentRangeVisit *FiringRangeVisit
-	CurrentRangeHits  int
-	LastMisses        int
-
-	TotalShots int
-	TotalHits  int
-
-	LastEventTime time.Time
+func (r *raceRun) finish() []race.LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.engine.Finish()
+	r.outputLog = append(r.outputLog, entries...)
+	r.appendOutgoing(entries)
+	r.finished = true
+	r.notifyLog()
+	return entries
 }
 
-type Event struct {
-	Time         time.Time
-	ID           int
-	CompetitorID int
-	ExtraParams  []string
-	RawLine      string
+// notifyLog wakes everyone waiting in logWait. r.mu must be held.
+func (r *raceRun) notifyLog() {
+	close(r.logChanged)
+	r.logChanged = make(chan struct{})
 }
 
-func parseDuration(durationStr string) (time.Duration, error) {
-	parts := strings.Split(durationStr, ":")
-	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid duration format: %s", durationStr)
-	}
-
-	secsParts := strings.Split(parts[2], ".")
-	var d time.Duration
-	var err error
-
-	h, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, err
-	}
-	m, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, err
-	}
-	s, err := strconv.Atoi(secsParts[0])
-	if err != nil {
-		return 0, err
-	}
-
-	d = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
-
-	if len(secsParts) == 2 {
-		ms, err := strconv.Atoi(secsParts[1])
-		if err != nil {
-			return 0, err
-		}
-		msStr := secsParts[1]
-		for len(msStr) < 3 {
-			msStr += "0"
-		}
-		ms, err = strconv.Atoi(msStr)
-		if err != nil {
-			return 0, err
+func (r *raceRun) appendOutgoing(entries []race.LogEntry) {
+	for _, entry := range entries {
+		if entry.Event != nil {
+			r.eventLog = append(r.eventLog, entry.Event.String())
 		}
-		d += time.Duration(ms) * time.Millisecond
 	}
-
-	return d, nil
 }
 
-func formatDuration(d time.Duration) string {
-	if d < 0 {
-		d = -d
-	}
-
-	totalSeconds := int64(d.Seconds())
-	milliseconds := d.Milliseconds() % 1000
-
-	hours := totalSeconds / 3600
-	minutes := (totalSeconds % 3600) / 60
-	seconds := totalSeconds % 60
-
-	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, seconds, milliseconds)
+// snapshot returns copies of the engine's competitors ordered by ID.
+func (r *raceRun) snapshot() []race.Competitor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.engine.Snapshot()
 }
 
-func loadConfig(path string) (*Config, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("error opening config file: %w", err)
-	}
-	defer file.Close()
-
-	bytes, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
-	}
-
-	var config Config
-	err = json.Unmarshal(bytes, &config)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing config JSON: %w", err)
-	}
-
-	config.parsedStart, err = time.Parse(configTimeLayout, config.Start)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing config start time: %w", err)
-	}
-
-	config.parsedStartDelta, err = parseDuration(config.StartDelta)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing config start delta '%s': %w", config.StartDelta, err)
-	}
-
-	return &config, nil
+// competitor returns a copy of the competitor with the given ID.
+func (r *raceRun) competitor(id int) (race.Competitor, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.engine.Competitor(id)
 }
 
-func parseEvent(line string) (*Event, error) {
-	line = strings.TrimSpace(line)
-	if len(line) == 0 {
-		return nil, nil
-	}
+// logSince returns the output log entries from index from on.
+func (r *raceRun) logSince(from int) []race.LogEntry {
+	entries, _, _ := r.logWait(from)
+	return entries
+}
 
-	parts := strings.SplitN(line, " ", 3)
-	if len(parts) < 3 {
-		return nil, fmt.Errorf("invalid event format: %s", line)
+// logWait is logSince that also returns a channel closed once there are
+// more entries, and whether the run has finished and no more will come.
+func (r *raceRun) logWait(from int) ([]race.LogEntry, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []race.LogEntry
+	if from >= 0 && from < len(r.outputLog) {
+		entries = append(entries, r.outputLog[from:]...)
 	}
+	return entries, r.logChanged, r.finished
+}
 
-	timeStr := parts[0]
-	eventIDStr := parts[1]
-	competitorIDStr := ""
-	extraParamsStr := ""
+// sortedSnapshot returns the engine's competitors in report order.
+func (r *raceRun) sortedSnapshot() []race.Competitor {
+	return sortedCompetitors(r.config, r.snapshot())
+}
 
-	remainingParts := strings.SplitN(parts[2], " ", 2)
-	competitorIDStr = remainingParts[0]
-	if len(remainingParts) > 1 {
-		extraParamsStr = remainingParts[1]
-	}
+// standings returns the result table rows for the engine's current state.
+func (r *raceRun) standings(config *race.Config) []string {
+	return standingsLines(config, r.snapshot())
+}
 
-	eventTime, err := time.Parse(eventTimeLayout, timeStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid time format %s: %w", timeStr, err)
-	}
+func sortedCompetitors(config *race.Config, competitors []race.Competitor) []race.Competitor {
+	race.SortStandings(config, competitors)
+	return competitors
+}
 
-	eventID, err := strconv.Atoi(eventIDStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid event ID %s: %w", eventIDStr, err)
+// standingsLines returns the result table rows for competitors: one row per
+// competitor, or a team row followed by its legs in a relay.
+func standingsLines(config *race.Config, competitors []race.Competitor) []string {
+	if config.Format == race.FormatRelay {
+		var lines []string
+		teams := race.TeamStandings(config, competitors)
+		for i := range teams {
+			lines = append(lines, race.TeamResultLines(config, &teams[i])...)
+		}
+		return lines
 	}
 
-	competitorID, err := strconv.Atoi(competitorIDStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid competitor ID %s: %w", competitorIDStr, err)
+	competitorList := sortedCompetitors(config, competitors)
+	lines := make([]string, 0, len(competitorList))
+	for i := range competitorList {
+		lines = append(lines, race.ResultLine(config, &competitorList[i]))
 	}
+	return lines
+}
 
-	var extraParams []string
-	if extraParamsStr != "" {
-		if eventID == 11 {
-			extraParams = []string{extraParamsStr}
-		} else {
-			extraParams = strings.Fields(extraParamsStr)
+// progressionLines formats the running rank progression of competitors in
+// report order under a "Rank Progression" heading.
+func progressionLines(config *race.Config, competitors []race.Competitor) []string {
+	lines := []string{"Rank Progression"}
+	competitorList := sortedCompetitors(config, competitors)
+	for i := range competitorList {
+		if line := race.ProgressionLine(&competitorList[i]); line != "" {
+			lines = append(lines, line)
 		}
 	}
-
-	return &Event{
-		Time:         eventTime,
-		ID:           eventID,
-		CompetitorID: competitorID,
-		ExtraParams:  extraParams,
-		RawLine:      line,
-	}, nil
+	return lines
 }
 
-func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("usage: go run main.go <config.json> <event>")
-		os.Exit(1)
-	}
-
-	configFile := os.Args[1]
-	eventsFile := os.Args[2]
-
-	config, err := loadConfig(configFile)
-	if err != nil {
-		fmt.Printf("error loading configuration: %v\n", err)
-		os.Exit(1)
+// writeJSONTable writes the JSON result table for competitors to w.
+func writeJSONTable(w io.Writer, config *race.Config, competitors []race.Competitor) error {
+	if config.Format == race.FormatRelay {
+		return race.WriteTeamJSONReport(w, config, race.TeamStandings(config, competitors))
 	}
+	return race.WriteJSONReport(w, config, sortedCompetitors(config, competitors))
+}
 
-	eventsLogFile, err := os.Open(eventsFile)
+func readEvents(path string, run *raceRun) ([]*race.Event, error) {
+	eventsLogFile, err := os.Open(path)
 	if err != nil {
-		fmt.Printf("error opening events file: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error opening events file: %w", err)
 	}
 	defer eventsLogFile.Close()
 
-	competitors := make(map[int]*Competitor)
-	var eventProcessingOrder []*Event
-	var outputLog []string
-
+	var events []*race.Event
 	scanner := bufio.NewScanner(eventsLogFile)
 	lineNumber := 0
 	for scanner.Scan() {
 		lineNumber++
 		line := scanner.Text()
-		event, err := parseEvent(line)
+		event, err := race.ParseEvent(line)
 		if err != nil {
-			fmt.Printf("error parsing event on line %d: %v\n", lineNumber, err)
+			run.parseError(lineNumber, line, err)
 			continue
 		}
 		if event != nil {
-			eventProcessingOrder = append(eventProcessingOrder, event)
+			event.Line = lineNumber
+			events = append(events, event)
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		fmt.Printf("error reading events file: %v\n", err)
-		os.Exit(1)
-	}
-
-	var lastProcessedTime time.Time
-
-	for _, event := range eventProcessingOrder {
-		for _, comp := range competitors {
-			if comp.Status == StatusScheduled || comp.Status == StatusOnStartLine {
-				if !comp.ScheduledStartTime.IsZero() && comp.ActualStartTime.IsZero() {
-					allowedStartWindowEnd := comp.ScheduledStartTime.Add(config.parsedStartDelta)
-					if event.Time.After(allowedStartWindowEnd) {
-						if comp.Status != StatusDisqualified && comp.Status != StatusNotStarted {
-							comp.Status = StatusNotStarted
-							comp.FinishTime = event.Time
-							msg := fmt.Sprintf("The competitor(%d) is disqualified (Did not start)", comp.ID)
-							outputLog = append(outputLog, fmt.Sprintf("%s %s", event.Time.Format(eventTimeLayout), msg))
-						}
-					}
-				}
-			}
-		}
-
-		competitor, exists := competitors[event.CompetitorID]
-
-		if event.ID == 1 {
-			if !exists {
-				competitor = &Competitor{
-					ID:                   event.CompetitorID,
-					Status:               StatusRegistered,
-					LapsCompleted:        []Lap{},
-					PenaltyLapsCompleted: []PenaltyLap{},
-					FiringRangeVisits:    []FiringRangeVisit{},
-					CurrentLapNumber:     0,
-				}
-				competitors[event.CompetitorID] = competitor
-				msg := fmt.Sprintf("The competitor(%d) registered", event.CompetitorID)
-				outputLog = append(outputLog, fmt.Sprintf("%s %s", event.Time.Format(eventTimeLayout), msg))
-			}
-		} else if !exists {
-			fmt.Printf("Warning: Event %d for unknown competitor %d at %s\n", event.ID, event.CompetitorID, event.Time.Format(eventTimeLayout))
-			continue
-		} else if competitor.Status == StatusFinished || competitor.Status == StatusNotFinished || competitor.Status == StatusDisqualified || competitor.Status == StatusNotStarted {
-			continue
-		}
-
-		competitor.LastEventTime = event.Time
-
-		logMsg := ""
-
-		switch event.ID {
-		case 2:
-			if len(event.ExtraParams) < 1 {
-				fmt.Printf("event 2 missing start time for competitor %d at %s\n", event.CompetitorID, event.Time.Format(eventTimeLayout))
-				continue
-			}
-			startTimeStr := event.ExtraParams[0]
-			scheduledTime, err := time.Parse(timeLayout, startTimeStr)
-			if err != nil {
-				fmt.Printf("event 2 invalid start time format '%s' for competitor %d: %v\n", startTimeStr, event.CompetitorID, err)
-				continue
-			}
-			baseDate := config.parsedStart.Truncate(24 * time.Hour)
-			competitor.ScheduledStartTime = baseDate.Add(time.Duration(scheduledTime.Hour())*time.Hour + time.Duration(scheduledTime.Minute())*time.Minute + time.Duration(scheduledTime.Second())*time.Second + time.Duration(scheduledTime.Nanosecond()))
-
-			competitor.Status = StatusScheduled
-			logMsg = fmt.Sprintf("The start time for the competitor(%d) was set by a draw to %s", event.CompetitorID, startTimeStr)
-
-		case 3:
-			if competitor.Status == StatusScheduled {
-				competitor.Status = StatusOnStartLine
-				logMsg = fmt.Sprintf("The competitor(%d) is on the start line", event.CompetitorID)
-			} else {
-				continue
-			}
-
-		case 4:
-			allowedStartWindowEnd := competitor.ScheduledStartTime.Add(config.parsedStartDelta)
-			if event.Time.After(allowedStartWindowEnd) && !competitor.ScheduledStartTime.IsZero() {
-				if competitor.Status != StatusNotStarted {
-					competitor.Status = StatusNotStarted
-					competitor.FinishTime = event.Time
-					msg := fmt.Sprintf("The competitor(%d) is disqualified (Started too late)", event.CompetitorID)
-					outputLog = append(outputLog, fmt.Sprintf("%s %s", event.Time.Format(eventTimeLayout), msg))
-					outputLog = append(outputLog, fmt.Sprintf("%s The competitor(%d) is disqualified", event.Time.Format(eventTimeLayout), event.CompetitorID))
-				}
-				continue
-			}
-
-			if competitor.Status == StatusOnStartLine || competitor.Status == StatusScheduled {
-				competitor.ActualStartTime = event.Time
-				competitor.Status = StatusStarted
-				competitor.CurrentLapNumber = 1
-				competitor.CurrentLapStart = event.Time
-				logMsg = fmt.Sprintf("The competitor(%d) has started", event.CompetitorID)
-			} else {
-				continue
-			}
-
-		case 5:
-			if competitor.Status == StatusStarted || competitor.Status == StatusOnLap {
-				competitor.Status = StatusOnRange
-				rangeNumStr := "unknown"
-				if len(event.ExtraParams) > 0 {
-					rangeNumStr = event.ExtraParams[0]
-				}
-				competitor.CurrentRangeVisit = &FiringRangeVisit{EnterTime: event.Time, Shots: 5} // Assume 5 shots
-				competitor.CurrentRangeHits = 0                                                   // Reset hits counter for this visit
-				logMsg = fmt.Sprintf("The competitor(%d) is on the firing range(%s)", event.CompetitorID, rangeNumStr)
-			} else {
-				continue
-			}
-
-		case 6:
-			if competitor.Status == StatusOnRange && competitor.CurrentRangeVisit != nil {
-				competitor.CurrentRangeHits++
-				targetNumStr := "unknown"
-				if len(event.ExtraParams) > 0 {
-					targetNumStr = event.ExtraParams[0]
-				}
-				logMsg = fmt.Sprintf("The target(%s) has been hit by competitor(%d)", targetNumStr, event.CompetitorID)
-			} else {
-				continue
-			}
-
-		case 7:
-			if competitor.Status == StatusOnRange && competitor.CurrentRangeVisit != nil {
-				competitor.Status = StatusOnLap
-				competitor.CurrentRangeVisit.ExitTime = event.Time
-				competitor.CurrentRangeVisit.Hits = competitor.CurrentRangeHits
-				competitor.TotalHits += competitor.CurrentRangeVisit.Hits
-				competitor.TotalShots += competitor.CurrentRangeVisit.Shots
-				competitor.LastMisses = competitor.CurrentRangeVisit.Shots - competitor.CurrentRangeVisit.Hits
-				competitor.FiringRangeVisits = append(competitor.FiringRangeVisits, *competitor.CurrentRangeVisit)
-				competitor.CurrentRangeVisit = nil
-
-				if competitor.Status != StatusFinished && competitor.Status != StatusNotFinished && competitor.Status != StatusDisqualified {
-					logMsg = fmt.Sprintf("The competitor(%d) left the firing range", event.CompetitorID)
-					if competitor.LastMisses == 0 {
-						competitor.Status = StatusOnLap
-					}
-				} else {
-					continue
-				}
-
-			} else {
-				continue
-			}
-
-		case 8:
-			if (competitor.Status == StatusOnLap || competitor.Status == StatusStarted) && competitor.LastMisses > 0 { // Should happen after leaving range with misses
-				competitor.Status = StatusInPenalty
-				competitor.CurrentPenaltyStart = event.Time
-				competitor.CurrentPenaltyDist = float64(competitor.LastMisses) * config.PenaltyLen
-				logMsg = fmt.Sprintf("The competitor(%d) entered the penalty laps", event.CompetitorID)
-			} else {
-				continue
-			}
-
-		case 9:
-			if competitor.Status == StatusInPenalty {
-				competitor.Status = StatusOnLap
-				penalty := PenaltyLap{
-					StartTime: competitor.CurrentPenaltyStart,
-					EndTime:   event.Time,
-					Distance:  competitor.CurrentPenaltyDist,
-				}
-				competitor.PenaltyLapsCompleted = append(competitor.PenaltyLapsCompleted, penalty)
-				competitor.CurrentPenaltyStart = time.Time{}
-				competitor.CurrentPenaltyDist = 0
-				competitor.LastMisses = 0
-				logMsg = fmt.Sprintf("The competitor(%d) left the penalty laps", event.CompetitorID)
-			} else {
-				continue
-			}
-
-		case 10:
-			if competitor.Status == StatusOnLap || competitor.Status == StatusStarted {
-				if competitor.LastMisses > 0 {
-					continue
-				}
-
-				lap := Lap{
-					Number:    competitor.CurrentLapNumber,
-					StartTime: competitor.CurrentLapStart,
-					EndTime:   event.Time,
-					Distance:  config.LapLen,
-				}
-				competitor.LapsCompleted = append(competitor.LapsCompleted, lap)
-				//logMsg = fmt.Sprintf("The competitor(%d) ended the main lap", event.CompetitorID)
-				outputLog = append(outputLog, fmt.Sprintf("%s The competitor(%d) ended the main lap", event.Time.Format(eventTimeLayout), event.CompetitorID))
-
-				if competitor.CurrentLapNumber == config.Laps {
-					competitor.Status = StatusFinished
-					competitor.FinishTime = event.Time
-					finishMsg := fmt.Sprintf("%s The competitor(%d) has finished", event.Time.Format(eventTimeLayout), event.CompetitorID)
-					outputLog = append(outputLog, finishMsg)
-				} else {
-					competitor.CurrentLapNumber++
-					competitor.CurrentLapStart = event.Time
-					competitor.Status = StatusOnLap
-				}
-			} else {
-				continue
-			}
-
-		case 11:
-			if competitor.Status != StatusFinished && competitor.Status != StatusNotFinished && competitor.Status != StatusDisqualified {
-				competitor.Status = StatusNotFinished
-				competitor.FinishTime = event.Time
-				if len(event.ExtraParams) > 0 {
-					competitor.Comment = event.ExtraParams[0]
-					logMsg = fmt.Sprintf("The competitor(%d) can`t continue: %s", event.CompetitorID, competitor.Comment)
-				} else {
-					logMsg = fmt.Sprintf("The competitor(%d) can`t continue", event.CompetitorID)
-				}
-			} else {
-				continue
-			}
-		}
-
-		if logMsg != "" {
-			outputLog = append(outputLog, fmt.Sprintf("%s %s", event.Time.Format(eventTimeLayout), logMsg))
-		}
-
-		lastProcessedTime = event.Time
+		return nil, fmt.Errorf("error reading events file: %w", err)
 	}
+	return events, nil
+}
 
-	for _, comp := range competitors {
-		if comp.Status == StatusScheduled || comp.Status == StatusOnStartLine {
-			if !comp.ScheduledStartTime.IsZero() && comp.ActualStartTime.IsZero() {
-				if comp.Status != StatusNotStarted {
-					comp.Status = StatusNotStarted
-					comp.FinishTime = lastProcessedTime
-					msg := fmt.Sprintf("The competitor(%d) is disqualified (Did not start by end of log)", comp.ID)
-					outputLog = append(outputLog, fmt.Sprintf("%s %s", lastProcessedTime.Format(eventTimeLayout), msg))
-				}
-			}
-		} else if comp.Status == StatusStarted || comp.Status == StatusOnLap || comp.Status == StatusOnRange || comp.Status == StatusInPenalty {
-			if comp.Status != StatusNotFinished {
-				comp.Status = StatusNotFinished
-				comp.FinishTime = lastProcessedTime
-				comp.Comment = "Did not finish before end of log"
-				msg := fmt.Sprintf("The competitor(%d) marked as NotFinished at end of log", comp.ID)
-				outputLog = append(outputLog, fmt.Sprintf("%s %s", lastProcessedTime.Format(eventTimeLayout), msg))
-			}
-		}
+// writeFile writes a file through write atomically: the content goes to a
+// temporary file in the same directory that is renamed over path only once
+// it is complete, so a failed run never leaves a half-written file behind.
+func writeFile(path string, write func(io.Writer) error) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
 	}
-
-	// Вывод
-
-	// Вывод выходного лога в консоль
-	fmt.Println("Output Log")
-	for _, logEntry := range outputLog {
-		fmt.Println(logEntry)
+	file, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
 	}
-	fmt.Println("End Output Log")
-	fmt.Println() // Spacer
+	defer os.Remove(file.Name())
 
-	// Сохраниение выходного лога в файл
-
-	outputFile1, err := os.Create("output_log.txt")
-	if err != nil {
-		fmt.Printf("error creating output log file: %v\n", err)
-		os.Exit(1)
+	if err := write(file); err != nil {
+		file.Close()
+		return err
 	}
-	defer outputFile1.Close()
-	writer1 := bufio.NewWriter(outputFile1)
-	for _, logEntry := range outputLog {
-		writer1.WriteString(logEntry + "\n")
+	if err := file.Close(); err != nil {
+		return err
 	}
-	writer1.Flush()
-
-	// Подготовка и вывод финального отчета
-	competitorList := make([]*Competitor, 0, len(competitors))
-	for _, c := range competitors {
-		competitorList = append(competitorList, c)
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
 	}
+	return os.Rename(file.Name(), path)
+}
 
-	sort.Slice(competitorList, func(i, j int) bool {
-		ci := competitorList[i]
-		cj := competitorList[j]
-
-		statusOrder := map[CompetitorStatus]int{
-			StatusFinished:     0,
-			StatusNotFinished:  1,
-			StatusNotStarted:   2,
-			StatusDisqualified: 3,
-			StatusOnLap:        4,
-			StatusInPenalty:    4,
-			StatusOnRange:      4,
-			StatusStarted:      4,
-			StatusOnStartLine:  4,
-			StatusScheduled:    4,
-			StatusRegistered:   4,
+func writeLines(path string, lines []string) error {
+	return writeFile(path, func(w io.Writer) error {
+		writer := bufio.NewWriter(w)
+		for _, line := range lines {
+			writer.WriteString(line + "\n")
 		}
+		return writer.Flush()
+	})
+}
 
-		statusI := statusOrder[ci.Status]
-		statusJ := statusOrder[cj.Status]
-
-		if statusI != statusJ {
-			return statusI < statusJ
-		}
+func writeBytes(path string, data []byte) error {
+	return writeFile(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
 
-		if ci.Status == StatusFinished && cj.Status == StatusFinished {
-			totalTimeI := ci.FinishTime.Sub(ci.ScheduledStartTime)
-			totalTimeJ := cj.FinishTime.Sub(cj.ScheduledStartTime)
-			return totalTimeI < totalTimeJ
-		}
+func writeCSV(path string, competitors []race.Competitor, write func(io.Writer, []race.Competitor) error) error {
+	return writeFile(path, func(w io.Writer) error {
+		return write(w, competitors)
+	})
+}
 
-		return ci.ID < cj.ID
+func writeDiagnostics(path string, violations []race.Violation) error {
+	return writeFile(path, func(w io.Writer) error {
+		return race.WriteDiagnostics(w, violations)
+	})
+}
 
+func writeState(path string, config *race.Config, competitors []race.Competitor) error {
+	return writeFile(path, func(w io.Writer) error {
+		return race.WriteState(w, config, competitors)
 	})
+}
 
-	outputFile2, err := os.Create("result_table.txt")
-	if err != nil {
-		fmt.Printf("error creating result table file: %v\n", err)
-		os.Exit(1)
-	}
-	defer outputFile2.Close()
-	writer2 := bufio.NewWriter(outputFile2)
-
-	fmt.Println("Resulting Table")
-	for _, c := range competitorList {
-		statusStr := ""
-		totalTimeStr := ""
-
-		switch c.Status {
-		case StatusFinished:
-			statusStr = "[Finished]"
-			if !c.FinishTime.IsZero() && !c.ScheduledStartTime.IsZero() {
-				totalTime := c.FinishTime.Sub(c.ScheduledStartTime)
-				totalTimeStr = formatDuration(totalTime)
-			} else {
-				totalTimeStr = "ERR: Missing Times"
-			}
-		case StatusNotFinished:
-			statusStr = "[NotFinished]"
-			totalTimeStr = "NotFinished"
-			if c.Comment != "" {
-				totalTimeStr += " (" + c.Comment + ")"
-			}
-		case StatusNotStarted:
-			statusStr = "[NotStarted]"
-			totalTimeStr = "NotStarted"
-		case StatusDisqualified:
-			statusStr = "[Disqualified]"
-			totalTimeStr = "Disqualified"
-		default:
-			statusStr = fmt.Sprintf("[%s]", c.Status)
-			totalTimeStr = string(c.Status)
+// loadConfig loads the config at path, printing every problem on its own
+// line if it is invalid.
+func loadConfig(path string) (*race.Config, bool) {
+	config, err := race.LoadConfig(path)
+	if err == nil {
+		return config, true
+	}
+	var configErr *race.ConfigError
+	if errors.As(err, &configErr) {
+		fmt.Printf("error loading configuration %s:\n", path)
+		for _, problem := range configErr.Problems {
+			fmt.Printf("  %v\n", problem)
 		}
+		return nil, false
+	}
+	fmt.Printf("error loading configuration: %v\n", err)
+	return nil, false
+}
 
-		var lapDetails []string
-		for i := 0; i < config.Laps; i++ {
-			detail := "{,}"
-			if i < len(c.LapsCompleted) {
-				lap := c.LapsCompleted[i]
-				if lap.Duration() > 0 {
-					detail = fmt.Sprintf("{%s, %.3f}", formatDuration(lap.Duration()), lap.AverageSpeed())
-				} else {
-					detail = fmt.Sprintf("{%s, 0.000}", formatDuration(lap.Duration()))
-				}
-
-			}
-			lapDetails = append(lapDetails, detail)
-		}
-		lapsStr := strings.Join(lapDetails, " ")
+// commands maps each subcommand to its entry point, which takes the
+// arguments after the subcommand name and returns the exit code.
+var commands = map[string]func(args []string) int{
+	"run":      runCommand,
+	"validate": validateCommand,
+	"report":   reportCommand,
+	"replay":   replayCommand,
+	"serve":    serveCommand,
+	"ingest":   ingestCommand,
+	"send":     sendCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: go run . <command> [flags] <args>
+
+commands:
+  run [flags] <config> <events>       process the events file and write the results (default)
+  validate [flags] <config> <events>  check the config and the events without writing results
+  report [flags] <state>              rebuild the result tables from a state saved by run --state
+  replay [flags] <config> <events>    step through the events one at a time
+  serve [flags] <config> <events>     follow the events file and serve live results over HTTP
+  ingest [flags] <config>             receive events over TCP or UDP from timing equipment
+  send [flags] <events>               send an events file to a running ingest, as timing equipment would
 
-		var totalPenaltyDuration time.Duration
-		var totalPenaltyDistance float64
-		for _, p := range c.PenaltyLapsCompleted {
-			totalPenaltyDuration += p.Duration()
-			totalPenaltyDistance += p.Distance
+Run "go run . <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if args[0] == "help" {
+			usage()
+			return
 		}
-		penaltyAvgSpeed := 0.0
-		if totalPenaltyDuration.Seconds() > 0 && totalPenaltyDistance > 0 {
-			penaltyAvgSpeed = totalPenaltyDistance / totalPenaltyDuration.Seconds()
+		if command, ok := commands[args[0]]; ok {
+			os.Exit(command(args[1:]))
 		}
-		penaltyStr := fmt.Sprintf("{%s, %.3f}", formatDuration(totalPenaltyDuration), penaltyAvgSpeed)
-
-		shootingStr := fmt.Sprintf("%d/%d", c.TotalHits, c.TotalShots)
-
-		// Вывод и сохранение в файл финального отчета
-		fmt.Printf("%s %d %s %s %s %s\n",
-			statusStr,
-			c.ID,
-			totalTimeStr,
-			lapsStr,
-			penaltyStr,
-			shootingStr,
-		)
-		writer2.WriteString(fmt.Sprintf("%s %d %s %s %s %s\n",
-			statusStr,
-			c.ID,
-			totalTimeStr,
-			lapsStr,
-			penaltyStr,
-			shootingStr,
-		))
 	}
-	fmt.Println("End Resulting Table")
-	writer2.Flush()
+	// Without a subcommand the arguments are those of run.
+	os.Exit(runCommand(args))
 }
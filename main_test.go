@@ -1,342 +1,474 @@
 package main
 
 import (
-	"fmt"
-	"math"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"solid-system/race"
 )
 
-const testTimeLayout = "2006-01-02T15:04:05.000Z"
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	return path
+}
 
-func mustParseTime(layout, value string) time.Time {
-	t, err := time.Parse(layout, value)
+func loadTestConfig(t *testing.T) *race.Config {
+	t.Helper()
+	config, err := race.LoadConfig(writeTestFile(t, "config.json", `{
+		"laps": 1,
+		"lapLen": 3651,
+		"penaltyLen": 50,
+		"firingLines": 1,
+		"start": "09:30:00",
+		"startDelta": "00:00:30"
+	}`))
 	if err != nil {
-		panic(fmt.Sprintf("Failed to parse time '%s' with layout '%s': %v", value, layout, err))
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
 	}
-	return t
+	return config
+}
+
+// syncBuffer lets the test read output while followEvents is writing it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
 }
 
-func createTempConfigFile(t *testing.T, content string) string {
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, out *syncBuffer, substr string) {
 	t.Helper()
-	dir := t.TempDir()
-	filePath := filepath.Join(dir, "config.json")
-	err := os.WriteFile(filePath, []byte(content), 0644)
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), substr) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, output so far:\n%s", substr, out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowEvents(t *testing.T) {
+	pollInterval := followPollInterval
+	t.Cleanup(func() { followPollInterval = pollInterval })
+	followPollInterval = 5 * time.Millisecond
+	config := loadTestConfig(t)
+	path := writeTestFile(t, "events", "[09:05:59.867] 1 1\n[09:15:00.841] 2 1 09:3")
+
+	run := newRaceRun(config)
+	out := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- followEvents(ctx, path, run, config, out)
+	}()
+
+	waitFor(t, out, "End Standings")
+	if strings.Contains(out.String(), "was set by a draw") {
+		t.Fatalf("partial line was applied before it was complete:\n%s", out.String())
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		t.Fatalf("Failed to create temp config file: %v", err)
+		t.Fatalf("Failed to open events file for append: %v", err)
+	}
+	f.WriteString("0:00.000\n[09:30:01.005] 4 1\n[09:45:00.000] 10 1\n")
+	f.Close()
+
+	waitFor(t, out, "[Finished] 1 00:15:00.000")
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("followEvents() unexpected error = %v", err)
+	}
+
+	want := []string{
+		"[09:05:59.867] The competitor(1) registered",
+		"[09:15:00.841] The start time for the competitor(1) was set by a draw to 09:30:00.000",
+		"[09:30:01.005] The competitor(1) has started",
+		"[09:45:00.000] The competitor(1) ended the main lap",
+		"[09:45:00.000] The competitor(1) has finished",
+	}
+	var got []string
+	for _, entry := range run.outputLog {
+		got = append(got, entry.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output log mismatch.\nGot:\n%s\nWant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
 	}
-	return filePath
 }
 
-func TestParseDuration(t *testing.T) {
-	tests := []struct {
-		name      string
-		input     string
-		want      time.Duration
-		expectErr bool
-	}{
-		{"Valid Full", "01:02:03.456", time.Hour + 2*time.Minute + 3*time.Second + 456*time.Millisecond, false},
-		{"Valid No Millis", "00:10:30", 10*time.Minute + 30*time.Second, false},
-		{"Valid Short Millis 1", "00:00:01.5", 1*time.Second + 500*time.Millisecond, false},
-		{"Valid Short Millis 2", "00:00:02.05", 2*time.Second + 50*time.Millisecond, false},
-		{"Zero Duration", "00:00:00.000", 0, false},
-		{"Invalid Format Colon", "01-02-03.456", 0, true},
-		{"Invalid Format Parts", "01:02", 0, true},
-		{"Invalid Format Too Many Parts", "01:02:03:04", 0, true},
-		{"Invalid Hour", "xx:02:03.456", 0, true},
-		{"Invalid Minute", "01:xx:03.456", 0, true},
-		{"Invalid Second", "01:02:xx.456", 0, true},
-		{"Invalid Millis", "01:02:03.xxx", 0, true},
-		{"Empty String", "", 0, true},
+func TestReplayEvents(t *testing.T) {
+	config := loadTestConfig(t)
+	run := newRaceRun(config)
+	var events []*race.Event
+	for _, line := range []string{"[09:05:59.867] 1 1", "[09:15:00.841] 2 1 09:30:00.000", "[09:30:01.005] 4 1"} {
+		event, err := race.ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		events = append(events, event)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseDuration(tt.input)
+	var out bytes.Buffer
+	step := promptStep(strings.NewReader("\nq\n"), io.Discard)
+	replayEvents(&out, run, events, false, step)
 
-			if (err != nil) != tt.expectErr {
-				t.Errorf("parseDuration(%q) error = %v, expectErr %v", tt.input, err, tt.expectErr)
-				return
-			}
-			if !tt.expectErr && got != tt.want {
-				t.Errorf("parseDuration(%q) = %v, want %v", tt.input, got, tt.want)
-			}
-		})
+	want := "> [09:05:59.867] 1 1\n" +
+		"[09:05:59.867] The competitor(1) registered\n" +
+		"> [09:15:00.841] 2 1 09:30:00.000\n" +
+		"[09:15:00.841] The start time for the competitor(1) was set by a draw to 09:30:00.000\n" +
+		"[09:15:00.841] The competitor(1) is disqualified (Did not start by end of log)\n" +
+		"Standings\n" +
+		"[NotStarted] 1 NotStarted {,} {00:00:00.000, 0.000} 0/0\n" +
+		"End Standings\n"
+	if out.String() != want {
+		t.Errorf("replayEvents() output:\n%s\nwant:\n%s", out.String(), want)
 	}
 }
 
-func TestFormatDuration(t *testing.T) {
-	tests := []struct {
-		name  string
-		input time.Duration
-		want  string
-	}{
-		{"Zero", 0, "00:00:00.000"},
-		{"Millis Only", 123 * time.Millisecond, "00:00:00.123"},
-		{"Seconds Only", 45 * time.Second, "00:00:45.000"},
-		{"Minutes Only", 15 * time.Minute, "00:15:00.000"},
-		{"Hours Only", 2 * time.Hour, "02:00:00.000"},
-		{"Full", 1*time.Hour + 23*time.Minute + 45*time.Second + 678*time.Millisecond, "01:23:45.678"},
-		{"Short Millis", 5*time.Second + 50*time.Millisecond, "00:00:05.050"}, // Needs padding
-		{"Long Duration", 25*time.Hour + 1*time.Minute + 1*time.Second + 1*time.Millisecond, "25:01:01.001"},
-		{"Negative Duration", -(1*time.Minute + 30*time.Second), "00:01:30.000"}, // Should format as positive
+func TestWriteFile(t *testing.T) {
+	path := writeTestFile(t, "result_table.txt", "previous results\n")
+
+	err := writeFile(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("encoding failed")
+	})
+	if err == nil {
+		t.Fatalf("writeFile() expected error, got nil")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "previous results\n" {
+		t.Errorf("file after failed write = %q, want the previous content", got)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := formatDuration(tt.input); got != tt.want {
-				t.Errorf("formatDuration(%v) = %q, want %q", tt.input, got, tt.want)
-			}
-		})
+	if err := writeLines(path, []string{"a", "b"}); err != nil {
+		t.Fatalf("writeLines() unexpected error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "a\nb\n" {
+		t.Errorf("file after writeLines() = %q, want %q", got, "a\nb\n")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v, want only the result table", names)
 	}
 }
 
-func TestLoadConfig(t *testing.T) {
-	validConfigContent := `{
-		"laps": 3,
-		"lapLen": 1500.0,
-		"penaltyLen": 150.0,
-		"firingLines": 10,
-		"start": "12:00:00",
-		"startDelta": "00:00:30.000"
-	}`
-	expectedStartTime, _ := time.Parse(configTimeLayout, "12:00:00")
-	expectedStartDelta, _ := parseDuration("00:00:30.000")
-
-	expectedConfig := &Config{
-		Laps:             3,
-		LapLen:           1500.0,
-		PenaltyLen:       150.0,
-		FiringLines:      10,
-		Start:            "12:00:00",
-		StartDelta:       "00:00:30.000",
-		parsedStart:      expectedStartTime,
-		parsedStartDelta: expectedStartDelta,
+func TestServer(t *testing.T) {
+	config := loadTestConfig(t)
+	run := newRaceRun(config)
+	for _, line := range []string{"[09:05:59.867] 1 1", "[09:15:00.841] 2 1 09:30:00.000", "[09:30:01.005] 4 1"} {
+		event, err := race.ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		run.apply(event)
 	}
+	server := httptest.NewServer(newServer(run))
+	defer server.Close()
 
 	tests := []struct {
-		name        string
-		setup       func(t *testing.T) string
-		want        *Config
-		wantErrStr  string
-		checkParsed bool
+		path       string
+		wantStatus int
+		wantBody   string
 	}{
-		{
-			name: "Valid Config",
-			setup: func(t *testing.T) string {
-				return createTempConfigFile(t, validConfigContent)
-			},
-			want:        expectedConfig,
-			wantErrStr:  "",
-			checkParsed: true,
-		},
-		{
-			name: "File Not Found",
-			setup: func(t *testing.T) string {
-				return filepath.Join(t.TempDir(), "nonexistent.json")
-			},
-			want:       nil,
-			wantErrStr: "error opening config file:",
-		},
-		{
-			name: "Invalid JSON",
-			setup: func(t *testing.T) string {
-				return createTempConfigFile(t, `{"laps": 3, "lapLen": 1500.0,`)
-			},
-			want:       nil,
-			wantErrStr: "error parsing config JSON:",
-		},
-		{
-			name: "Invalid Start Time Format",
-			setup: func(t *testing.T) string {
-				invalidTimeContent := strings.Replace(validConfigContent, `"12:00:00"`, `"12-00-00"`, 1)
-				return createTempConfigFile(t, invalidTimeContent)
-			},
-			want:       nil,
-			wantErrStr: "error parsing config start time:",
-		},
-		{
-			name: "Invalid Start Delta Format",
-			setup: func(t *testing.T) string {
-				invalidDeltaContent := strings.Replace(validConfigContent, `"00:00:30.000"`, `"invalid"`, 1)
-				return createTempConfigFile(t, invalidDeltaContent)
-			},
-			want:       nil,
-			wantErrStr: "error parsing config start delta 'invalid':",
-		},
-		{
-			name: "Missing Field (Laps)",
-			setup: func(t *testing.T) string {
-				missingFieldContent := `{
-					"lapLen": 1500.0,
-					"penaltyLen": 150.0,
-					"firingLines": 10,
-					"start": "12:00:00",
-					"startDelta": "00:00:30.000"
-				}`
-				return createTempConfigFile(t, missingFieldContent)
-			},
-			want: &Config{
-				Laps:             0, // Zero value
-				LapLen:           1500.0,
-				PenaltyLen:       150.0,
-				FiringLines:      10,
-				Start:            "12:00:00",
-				StartDelta:       "00:00:30.000",
-				parsedStart:      expectedStartTime,
-				parsedStartDelta: expectedStartDelta,
-			},
-			wantErrStr:  "",
-			checkParsed: true,
-		},
+		{"/standings", http.StatusOK, `"status": "Started"`},
+		{"/competitors/1", http.StatusOK, `"id": 1`},
+		{"/competitors/1/laps", http.StatusOK, `[`},
+		{"/competitors/2", http.StatusNotFound, "competitor 2 not found"},
+		{"/competitors/x", http.StatusBadRequest, `invalid competitor ID "x"`},
+		{"/log?since=2", http.StatusOK, `"message": "The competitor(1) has started"`},
+		{"/log?since=-1", http.StatusBadRequest, "invalid since"},
 	}
-
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			configPath := tt.setup(t)
-			got, err := loadConfig(configPath)
-
-			if tt.wantErrStr != "" {
-				if err == nil {
-					t.Errorf("loadConfig() expected error containing %q, but got nil", tt.wantErrStr)
-				} else if !strings.Contains(err.Error(), tt.wantErrStr) {
-					t.Errorf("loadConfig() error = %v, want error containing %q", err, tt.wantErrStr)
-				}
-			} else {
-				if err != nil {
-					t.Errorf("loadConfig() unexpected error = %v", err)
-				}
-				if got == nil && tt.want != nil {
-					t.Errorf("loadConfig() got nil, want non-nil")
-					return
-				}
-				if got != nil && tt.want == nil {
-					t.Errorf("loadConfig() got non-nil, want nil")
-					return
-				}
-				if got != nil && tt.want != nil {
-					if got.Laps != tt.want.Laps || got.LapLen != tt.want.LapLen ||
-						got.PenaltyLen != tt.want.PenaltyLen || got.FiringLines != tt.want.FiringLines ||
-						got.Start != tt.want.Start || got.StartDelta != tt.want.StartDelta {
-						t.Errorf("loadConfig() basic fields mismatch. Got %+v, want %+v", got, tt.want)
-					}
-					if tt.checkParsed {
-						if !got.parsedStart.Equal(tt.want.parsedStart) {
-							t.Errorf("loadConfig() parsedStart mismatch. Got %v, want %v", got.parsedStart, tt.want.parsedStart)
-						}
-						if got.parsedStartDelta != tt.want.parsedStartDelta {
-							t.Errorf("loadConfig() parsedStartDelta mismatch. Got %v, want %v", got.parsedStartDelta, tt.want.parsedStartDelta)
-						}
-					}
-				}
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("GET %s unexpected error = %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("GET %s body = %s, want it to contain %q", tt.path, body, tt.wantBody)
 			}
 		})
 	}
+
+	resp, err := http.Get(server.URL + "/log?since=2")
+	if err != nil {
+		t.Fatalf("GET /log unexpected error = %v", err)
+	}
+	defer resp.Body.Close()
+	var entries []logMessage
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatalf("decoding /log response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Index != 2 || entries[0].Time != "09:30:01.005" {
+		t.Errorf("GET /log?since=2 = %+v, want the one entry at index 2", entries)
+	}
 }
 
-func TestLap_Duration(t *testing.T) {
-	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:00:00.000Z")
-	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:05:30.500Z")
+func TestServerEvents(t *testing.T) {
+	config := loadTestConfig(t)
+	run := newRaceRun(config)
+	apply := func(line string) {
+		t.Helper()
+		event, err := race.ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		run.apply(event)
+	}
+	apply("[09:05:59.867] 1 1")
+	apply("[09:15:00.841] 2 1 09:30:00.000")
+	server := httptest.NewServer(newServer(run))
+	defer server.Close()
 
-	tests := []struct {
-		name string
-		lap  Lap
-		want time.Duration
-	}{
-		{"Valid Duration", Lap{StartTime: t1, EndTime: t2}, 5*time.Minute + 30*time.Second + 500*time.Millisecond},
-		{"Zero Start Time", Lap{StartTime: time.Time{}, EndTime: t2}, 0},
-		{"Zero End Time", Lap{StartTime: t1, EndTime: time.Time{}}, 0},
-		{"Zero Both Times", Lap{StartTime: time.Time{}, EndTime: time.Time{}}, 0},
-		{"Same Start/End Time", Lap{StartTime: t1, EndTime: t1}, 0},
+	// A client reconnecting after entry 0 gets everything from entry 1 on.
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events unexpected error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("GET /events Content-Type = %q, want text/event-stream", ct)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.lap.Duration(); got != tt.want {
-				t.Errorf("Lap.Duration() = %v, want %v", got, tt.want)
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var frame []string
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				frame = append(frame, scanner.Text())
+				continue
 			}
-		})
+			events <- strings.Join(frame, "\n")
+			frame = nil
+		}
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return ""
+		}
 	}
-}
 
-func TestLap_AverageSpeed(t *testing.T) {
-	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:00:00.000Z")
-	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:02:00.000Z")
-	const tolerance = 1e-9
+	want := "id: 1\nevent: log\n" +
+		`data: {"index":1,"time":"09:15:00.841","message":"The start time for the competitor(1) was set by a draw to 09:30:00.000","competitorId":1,"status":"Scheduled"}`
+	if got := next(); got != want {
+		t.Errorf("first event:\n%s\nwant:\n%s", got, want)
+	}
 
-	tests := []struct {
-		name string
-		lap  Lap
-		want float64
-	}{
-		{"Valid Speed", Lap{StartTime: t1, EndTime: t2, Distance: 1500.0}, 1500.0 / 120.0},
-		{"Zero Distance", Lap{StartTime: t1, EndTime: t2, Distance: 0.0}, 0.0},
-		{"Zero Duration", Lap{StartTime: t1, EndTime: t1, Distance: 1500.0}, 0.0},
-		{"Zero Start Time", Lap{StartTime: time.Time{}, EndTime: t2, Distance: 1500.0}, 0.0},
-		{"Zero End Time", Lap{StartTime: t1, EndTime: time.Time{}, Distance: 1500.0}, 0.0},
+	apply("[09:30:01.005] 4 1")
+	want = "id: 2\nevent: log\n" +
+		`data: {"index":2,"time":"09:30:01.005","message":"The competitor(1) has started","competitorId":1,"status":"Started"}`
+	if got := next(); got != want {
+		t.Errorf("event after apply:\n%s\nwant:\n%s", got, want)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := tt.lap.AverageSpeed()
-			if math.Abs(got-tt.want) > tolerance {
-				t.Errorf("Lap.AverageSpeed() = %v, want %v", got, tt.want)
+	run.finish()
+	want = "id: 3\nevent: log\n" +
+		`data: {"index":3,"time":"09:30:01.005","message":"The competitor(1) marked as NotFinished at end of log","competitorId":1,"status":"NotFinished"}`
+	if got := next(); got != want {
+		t.Errorf("event after finish:\n%s\nwant:\n%s", got, want)
+	}
+	if got := next(); got != "event: end\ndata: {}" {
+		t.Errorf("last event = %q, want the end event", got)
+	}
+}
+
+func TestIngest(t *testing.T) {
+	lines := []string{
+		"[09:05:59.867] 1 1",
+		"",
+		"[09:15:00.841] 2 1 09:30:00.000",
+		"[09:15:01.000] 3 2",
+		"garbage",
+		"[09:30:01.005] 4 1",
+		"[09:30:00.000] 5 1 1",
+		"[09:30:02.000] 1 1",
+	}
+	want := "> [09:05:59.867] 1 1\n" +
+		"< ok 1\n" +
+		"> [09:15:00.841] 2 1 09:30:00.000\n" +
+		"< ok 2\n" +
+		"> [09:15:01.000] 3 2\n" +
+		`< error line 3: [unknown_competitor] competitor(2) Unregistered: event "[09:15:01.000] 3 2": unknown competitor` + "\n" +
+		"> garbage\n" +
+		`< error line 4: [malformed] "garbage": invalid event format: garbage` + "\n" +
+		"> [09:30:01.005] 4 1\n" +
+		"< ok 5\n" +
+		"> [09:30:00.000] 5 1 1\n" +
+		`< ok 6 warning line 6: [bad_time] competitor(1) Started: event "[09:30:00.000] 5 1 1": event time is before the previous event at [09:30:01.005]` + "\n" +
+		"> [09:30:02.000] 1 1\n" +
+		`< error line 7: [wrong_state] competitor(1) OnRange: event "[09:30:02.000] 1 1": competitor is already registered` + "\n"
+	wantLog := "[09:05:59.867] The competitor(1) registered\n" +
+		"[09:15:00.841] The start time for the competitor(1) was set by a draw to 09:30:00.000\n" +
+		"[09:30:01.005] The competitor(1) has started\n" +
+		"[09:30:00.000] The competitor(1) is on the firing range(1)\n"
+
+	for _, network := range []string{"tcp", "udp"} {
+		t.Run(network, func(t *testing.T) {
+			run := newRaceRun(loadTestConfig(t))
+			run.warnings = io.Discard
+			log := &syncBuffer{}
+			in := &ingester{run: run, w: log}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+
+			var addr string
+			if network == "udp" {
+				conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatalf("ListenPacket() unexpected error = %v", err)
+				}
+				addr = conn.LocalAddr().String()
+				go func() { done <- in.serveUDP(ctx, conn) }()
+			} else {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatalf("Listen() unexpected error = %v", err)
+				}
+				addr = listener.Addr().String()
+				go func() { done <- in.serveTCP(ctx, listener) }()
+			}
+
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				t.Fatalf("Dial() unexpected error = %v", err)
+			}
+			defer conn.Close()
+			var out bytes.Buffer
+			if err := sendEvents(&out, conn, lines, 0); err != nil {
+				t.Fatalf("sendEvents() unexpected error = %v", err)
+			}
+
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("serve unexpected error = %v", err)
+			}
+			if out.String() != want {
+				t.Errorf("sendEvents() output:\n%s\nwant:\n%s", out.String(), want)
+			}
+			if log.String() != wantLog {
+				t.Errorf("ingested log:\n%s\nwant:\n%s", log.String(), wantLog)
+			}
+			if got := len(run.violations()); got != 4 {
+				t.Errorf("violations = %d, want 4", got)
 			}
 		})
 	}
 }
 
-func TestPenaltyLap_Duration(t *testing.T) {
-	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:10:00.000Z")
-	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:10:45.250Z")
+func TestBundledExampleIsValid(t *testing.T) {
+	config, err := race.LoadConfig("config.json")
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	run := newRaceRun(config)
+	run.warnings = io.Discard
+	events, err := readEvents("events", run)
+	if err != nil {
+		t.Fatalf("readEvents() unexpected error = %v", err)
+	}
+	for _, event := range events {
+		run.apply(event)
+	}
+	run.finish()
+	for _, v := range run.violations() {
+		t.Errorf("bundled example: %s", v.Error())
+	}
+}
 
-	tests := []struct {
-		name string
-		lap  PenaltyLap
-		want time.Duration
-	}{
-		{"Valid Duration", PenaltyLap{StartTime: t1, EndTime: t2}, 45*time.Second + 250*time.Millisecond},
-		{"Zero Start Time", PenaltyLap{StartTime: time.Time{}, EndTime: t2}, 0},
-		{"Zero End Time", PenaltyLap{StartTime: t1, EndTime: time.Time{}}, 0},
-		{"Zero Both Times", PenaltyLap{StartTime: time.Time{}, EndTime: time.Time{}}, 0},
-		{"Same Start/End Time", PenaltyLap{StartTime: t1, EndTime: t1}, 0},
+func TestRunCommand_StdoutOnly(t *testing.T) {
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
 	}
+	defer devNull.Close()
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.lap.Duration(); got != tt.want {
-				t.Errorf("PenaltyLap.Duration() = %v, want %v", got, tt.want)
-			}
-		})
+	outputDir := filepath.Join(t.TempDir(), "out")
+	if code := runCommand([]string{"--output", "stdout", "--output-dir", outputDir, "config.json", "events"}); code != 0 {
+		t.Fatalf("runCommand() = %d, want 0", code)
+	}
+	if _, err := os.Stat(outputDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("output directory stat error = %v, want it not created with --output stdout", err)
 	}
 }
 
-func TestPenaltyLap_AverageSpeed(t *testing.T) {
-	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:10:00.000Z")
-	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:10:30.000Z")
-	const tolerance = 1e-9
+func TestRunCommand_ReingestEventsLog(t *testing.T) {
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
 
-	tests := []struct {
-		name string
-		lap  PenaltyLap
-		want float64
-	}{
-		{"Valid Speed", PenaltyLap{StartTime: t1, EndTime: t2, Distance: 150.0}, 150.0 / 30.0},
-		{"Zero Distance", PenaltyLap{StartTime: t1, EndTime: t2, Distance: 0.0}, 0.0},
-		{"Zero Duration", PenaltyLap{StartTime: t1, EndTime: t1, Distance: 150.0}, 0.0},
-		{"Zero Start Time", PenaltyLap{StartTime: time.Time{}, EndTime: t2, Distance: 150.0}, 0.0},
-		{"Zero End Time", PenaltyLap{StartTime: t1, EndTime: time.Time{}, Distance: 150.0}, 0.0},
+	firstDir := filepath.Join(t.TempDir(), "first")
+	if code := runCommand([]string{"--output", "files", "--output-dir", firstDir, "config.json", "events"}); code != 0 {
+		t.Fatalf("runCommand() = %d, want 0", code)
+	}
+	secondDir := filepath.Join(t.TempDir(), "second")
+	if code := runCommand([]string{"--output", "files", "--output-dir", secondDir, "config.json", filepath.Join(firstDir, "output_events.txt")}); code != 0 {
+		t.Fatalf("runCommand() on the events log = %d, want 0", code)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := tt.lap.AverageSpeed()
-			if math.Abs(got-tt.want) > tolerance {
-				t.Errorf("PenaltyLap.AverageSpeed() = %v, want %v", got, tt.want)
-			}
-		})
+	for _, name := range []string{"output_log.txt", "output_events.txt"} {
+		want, err := os.ReadFile(filepath.Join(firstDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		got, err := os.ReadFile(filepath.Join(secondDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read re-ingested %s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("re-ingesting output_events.txt changed %s:\ngot:\n%s\nwant:\n%s", name, got, want)
+		}
 	}
 }
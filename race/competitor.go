@@ -0,0 +1,342 @@
+package race
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Lap struct {
+	Number    int
+	StartTime time.Time
+	EndTime   time.Time
+	Distance  float64
+	// Splits are the intermediate checkpoints passed on the lap, in order.
+	Splits []Split
+	// Rank is the competitor's position at the end of the lap: among
+	// those who had completed it by then while the race runs, and among
+	// everyone who completed it once the race is finished.
+	Rank int
+}
+
+// Split is the passing of an intermediate checkpoint, Distance metres from
+// the start of the lap.
+type Split struct {
+	Checkpoint int
+	Time       time.Time
+	Distance   float64
+}
+
+// Sector is the stretch of a lap between two consecutive timing points.
+type Sector struct {
+	// From and To are distances from the start of the lap.
+	From      float64
+	To        float64
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+func (s Sector) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+func (s Sector) AverageSpeed() float64 {
+	durationSeconds := s.Duration().Seconds()
+	if durationSeconds <= 0 || s.To <= s.From {
+		return 0.0
+	}
+	return (s.To - s.From) / durationSeconds
+}
+
+// Sectors splits the lap at its checkpoints, or returns nil if it has none.
+// A checkpoint that was not recorded merges the sectors either side of it.
+func (l Lap) Sectors() []Sector {
+	if len(l.Splits) == 0 {
+		return nil
+	}
+	sectors := make([]Sector, 0, len(l.Splits)+1)
+	from, start := 0.0, l.StartTime
+	for _, split := range l.Splits {
+		sectors = append(sectors, Sector{From: from, To: split.Distance, StartTime: start, EndTime: split.Time})
+		from, start = split.Distance, split.Time
+	}
+	return append(sectors, Sector{From: from, To: l.Distance, StartTime: start, EndTime: l.EndTime})
+}
+
+func (l Lap) Duration() time.Duration {
+	if l.StartTime.IsZero() || l.EndTime.IsZero() {
+		return 0
+	}
+	return l.EndTime.Sub(l.StartTime)
+}
+
+func (l Lap) AverageSpeed() float64 {
+	durationSeconds := l.Duration().Seconds()
+	if durationSeconds <= 0 || l.Distance <= 0 {
+		return 0.0
+	}
+	return l.Distance / durationSeconds
+}
+
+type PenaltyLap struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Distance  float64
+	// Misses is the number of penalty loops owed for the preceding
+	// firing range visit.
+	Misses int
+}
+
+func (p PenaltyLap) Duration() time.Duration {
+	if p.StartTime.IsZero() || p.EndTime.IsZero() {
+		return 0
+	}
+	return p.EndTime.Sub(p.StartTime)
+}
+
+func (p PenaltyLap) AverageSpeed() float64 {
+	durationSeconds := p.Duration().Seconds()
+	if durationSeconds <= 0 || p.Distance <= 0 {
+		return 0.0
+	}
+	return p.Distance / durationSeconds
+}
+
+// ShootingPosition is the position a firing range visit is shot from.
+type ShootingPosition string
+
+const (
+	PositionProne    ShootingPosition = "prone"
+	PositionStanding ShootingPosition = "standing"
+)
+
+type FiringRangeVisit struct {
+	EnterTime time.Time
+	ExitTime  time.Time
+	Hits      int
+	Shots     int
+	// SpareRounds is the number of extra rounds the competitor could load.
+	SpareRounds int
+	// RoundsFired is the number of rounds fired on the visit, spare
+	// rounds included.
+	RoundsFired int
+	// HitTargets lists the targets hit, numbered from 1, in hit order.
+	HitTargets []int
+	// Lap is the main lap the visit was made on and FiringLine the firing
+	// line number from event 5, or 0 if it was missing or invalid.
+	Lap        int
+	FiringLine int
+	// Position is taken from Config.ShootingSequence, or empty if the
+	// config has none.
+	Position ShootingPosition
+	// Rank is the competitor's position on leaving the range among those
+	// who had made as many visits by then, or, once the race is finished,
+	// among everyone who did.
+	Rank int
+}
+
+// IsHit reports whether target has already been hit on this visit.
+func (v FiringRangeVisit) IsHit(target int) bool {
+	for _, t := range v.HitTargets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HitPattern shows each target in order as 'X' if it was hit and '-' if
+// it is still standing.
+func (v FiringRangeVisit) HitPattern() string {
+	pattern := make([]byte, v.Shots)
+	for i := range pattern {
+		pattern[i] = '-'
+		if v.IsHit(i + 1) {
+			pattern[i] = 'X'
+		}
+	}
+	return string(pattern)
+}
+
+// Duration is the time spent on the range, or zero if the visit is not
+// complete.
+func (v FiringRangeVisit) Duration() time.Duration {
+	if v.EnterTime.IsZero() || v.ExitTime.IsZero() {
+		return 0
+	}
+	return v.ExitTime.Sub(v.EnterTime)
+}
+
+func (v FiringRangeVisit) clone() FiringRangeVisit {
+	v.HitTargets = append([]int(nil), v.HitTargets...)
+	return v
+}
+
+type CompetitorStatus string
+
+const (
+	StatusRegistered   CompetitorStatus = "Registered"
+	StatusScheduled    CompetitorStatus = "Scheduled"
+	StatusOnStartLine  CompetitorStatus = "OnStartLine"
+	StatusStarted      CompetitorStatus = "Started"
+	StatusOnLap        CompetitorStatus = "OnLap"
+	StatusOnRange      CompetitorStatus = "OnRange"
+	StatusInPenalty    CompetitorStatus = "InPenalty"
+	StatusFinished     CompetitorStatus = "Finished"
+	StatusNotFinished  CompetitorStatus = "NotFinished"
+	StatusDisqualified CompetitorStatus = "Disqualified"
+	StatusNotStarted   CompetitorStatus = "NotStarted"
+)
+
+// IsFinal reports whether no further events can change a competitor in
+// this status.
+func (s CompetitorStatus) IsFinal() bool {
+	return s == StatusFinished || s == StatusNotFinished || s == StatusDisqualified || s == StatusNotStarted
+}
+
+type Competitor struct {
+	ID                 int
+	Status             CompetitorStatus
+	ScheduledStartTime time.Time
+	ActualStartTime    time.Time
+	FinishTime         time.Time
+	// FinishOrder is the order in which finishers crossed the line, from
+	// 1; it breaks photo-finish ties between equal finish times.
+	FinishOrder int
+	Comment     string
+
+	// Team and Leg place the competitor in a relay; both are zero in
+	// other formats.
+	Team int
+	Leg  int
+
+	LapsCompleted    []Lap
+	CurrentLapNumber int
+	CurrentLapStart  time.Time
+	// CurrentSplits are the checkpoints passed so far on the current lap.
+	CurrentSplits []Split
+
+	PenaltyLapsCompleted []PenaltyLap
+	CurrentPenaltyStart  time.Time
+	CurrentPenaltyDist   float64
+
+	FiringRangeVisits []FiringRangeVisit
+	CurrentRangeVisit *FiringRangeVisit
+	CurrentRangeHits  int
+	LastMisses        int
+
+	TotalShots int
+	TotalHits  int
+
+	// TimePenalty is added to the total time: penalty time for misses in
+	// the individual format, and time for penalty loops that were not
+	// served.
+	TimePenalty time.Duration
+
+	LastEventTime time.Time
+}
+
+// PositionTotal is a competitor's shooting in one position.
+type PositionTotal struct {
+	Position ShootingPosition
+	Hits     int
+	Shots    int
+}
+
+// PositionTotals sums hits and shots per shooting position, in the order
+// the positions were first shot. Visits without a position are skipped.
+func (c *Competitor) PositionTotals() []PositionTotal {
+	var totals []PositionTotal
+	for _, visit := range c.FiringRangeVisits {
+		if visit.Position == "" {
+			continue
+		}
+		i := 0
+		for i < len(totals) && totals[i].Position != visit.Position {
+			i++
+		}
+		if i == len(totals) {
+			totals = append(totals, PositionTotal{Position: visit.Position})
+		}
+		totals[i].Hits += visit.Hits
+		totals[i].Shots += visit.RoundsFired
+	}
+	return totals
+}
+
+// RankPoint is a competitor's running rank at one timing point.
+type RankPoint struct {
+	// Point names the timing point, e.g. "lap 1" or "shooting 2".
+	Point string
+	Time  time.Time
+	Rank  int
+	// Change is the number of places gained since the previous point,
+	// negative if places were lost.
+	Change int
+}
+
+// RankProgression lists the competitor's rank at each firing
+// range exit and lap end in the order they passed them.
+func (c *Competitor) RankProgression() []RankPoint {
+	var points []RankPoint
+	for i, visit := range c.FiringRangeVisits {
+		if visit.Rank > 0 {
+			points = append(points, RankPoint{Point: fmt.Sprintf("shooting %d", i+1), Time: visit.ExitTime, Rank: visit.Rank})
+		}
+	}
+	for _, lap := range c.LapsCompleted {
+		if lap.Rank > 0 {
+			points = append(points, RankPoint{Point: fmt.Sprintf("lap %d", lap.Number), Time: lap.EndTime, Rank: lap.Rank})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	for i := 1; i < len(points); i++ {
+		points[i].Change = points[i-1].Rank - points[i].Rank
+	}
+	return points
+}
+
+// TotalTime is the time from the scheduled start to the finish plus any
+// time penalty, or zero if the start or finish is unknown.
+func (c *Competitor) TotalTime() time.Duration {
+	if c.FinishTime.IsZero() || c.ScheduledStartTime.IsZero() {
+		return 0
+	}
+	return c.FinishTime.Sub(c.ScheduledStartTime) + c.TimePenalty
+}
+
+// PenaltyTotals sums the duration and distance of all completed penalty
+// laps and returns them with the resulting average speed.
+func (c *Competitor) PenaltyTotals() (duration time.Duration, distance float64, averageSpeed float64) {
+	for _, p := range c.PenaltyLapsCompleted {
+		duration += p.Duration()
+		distance += p.Distance
+	}
+	if duration.Seconds() > 0 && distance > 0 {
+		averageSpeed = distance / duration.Seconds()
+	}
+	return duration, distance, averageSpeed
+}
+
+// clone returns a deep copy of c that shares no slices or pointers with it.
+func (c *Competitor) clone() Competitor {
+	cp := *c
+	cp.LapsCompleted = make([]Lap, len(c.LapsCompleted))
+	for i, lap := range c.LapsCompleted {
+		lap.Splits = append([]Split(nil), lap.Splits...)
+		cp.LapsCompleted[i] = lap
+	}
+	cp.CurrentSplits = append([]Split(nil), c.CurrentSplits...)
+	cp.PenaltyLapsCompleted = append([]PenaltyLap{}, c.PenaltyLapsCompleted...)
+	cp.FiringRangeVisits = make([]FiringRangeVisit, len(c.FiringRangeVisits))
+	for i, visit := range c.FiringRangeVisits {
+		cp.FiringRangeVisits[i] = visit.clone()
+	}
+	if c.CurrentRangeVisit != nil {
+		visit := c.CurrentRangeVisit.clone()
+		cp.CurrentRangeVisit = &visit
+	}
+	return cp
+}
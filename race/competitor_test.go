@@ -0,0 +1,122 @@
+package race
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+const testTimeLayout = "2006-01-02T15:04:05.000Z"
+
+func mustParseTime(layout, value string) time.Time {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse time '%s' with layout '%s': %v", value, layout, err))
+	}
+	return t
+}
+
+func TestLap_Duration(t *testing.T) {
+	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:00:00.000Z")
+	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:05:30.500Z")
+
+	tests := []struct {
+		name string
+		lap  Lap
+		want time.Duration
+	}{
+		{"Valid Duration", Lap{StartTime: t1, EndTime: t2}, 5*time.Minute + 30*time.Second + 500*time.Millisecond},
+		{"Zero Start Time", Lap{StartTime: time.Time{}, EndTime: t2}, 0},
+		{"Zero End Time", Lap{StartTime: t1, EndTime: time.Time{}}, 0},
+		{"Zero Both Times", Lap{StartTime: time.Time{}, EndTime: time.Time{}}, 0},
+		{"Same Start/End Time", Lap{StartTime: t1, EndTime: t1}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lap.Duration(); got != tt.want {
+				t.Errorf("Lap.Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLap_AverageSpeed(t *testing.T) {
+	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:00:00.000Z")
+	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:02:00.000Z")
+	const tolerance = 1e-9
+
+	tests := []struct {
+		name string
+		lap  Lap
+		want float64
+	}{
+		{"Valid Speed", Lap{StartTime: t1, EndTime: t2, Distance: 1500.0}, 1500.0 / 120.0},
+		{"Zero Distance", Lap{StartTime: t1, EndTime: t2, Distance: 0.0}, 0.0},
+		{"Zero Duration", Lap{StartTime: t1, EndTime: t1, Distance: 1500.0}, 0.0},
+		{"Zero Start Time", Lap{StartTime: time.Time{}, EndTime: t2, Distance: 1500.0}, 0.0},
+		{"Zero End Time", Lap{StartTime: t1, EndTime: time.Time{}, Distance: 1500.0}, 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lap.AverageSpeed()
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("Lap.AverageSpeed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPenaltyLap_Duration(t *testing.T) {
+	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:10:00.000Z")
+	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:10:45.250Z")
+
+	tests := []struct {
+		name string
+		lap  PenaltyLap
+		want time.Duration
+	}{
+		{"Valid Duration", PenaltyLap{StartTime: t1, EndTime: t2}, 45*time.Second + 250*time.Millisecond},
+		{"Zero Start Time", PenaltyLap{StartTime: time.Time{}, EndTime: t2}, 0},
+		{"Zero End Time", PenaltyLap{StartTime: t1, EndTime: time.Time{}}, 0},
+		{"Zero Both Times", PenaltyLap{StartTime: time.Time{}, EndTime: time.Time{}}, 0},
+		{"Same Start/End Time", PenaltyLap{StartTime: t1, EndTime: t1}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lap.Duration(); got != tt.want {
+				t.Errorf("PenaltyLap.Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPenaltyLap_AverageSpeed(t *testing.T) {
+	t1 := mustParseTime(testTimeLayout, "2023-10-26T10:10:00.000Z")
+	t2 := mustParseTime(testTimeLayout, "2023-10-26T10:10:30.000Z")
+	const tolerance = 1e-9
+
+	tests := []struct {
+		name string
+		lap  PenaltyLap
+		want float64
+	}{
+		{"Valid Speed", PenaltyLap{StartTime: t1, EndTime: t2, Distance: 150.0}, 150.0 / 30.0},
+		{"Zero Distance", PenaltyLap{StartTime: t1, EndTime: t2, Distance: 0.0}, 0.0},
+		{"Zero Duration", PenaltyLap{StartTime: t1, EndTime: t1, Distance: 150.0}, 0.0},
+		{"Zero Start Time", PenaltyLap{StartTime: time.Time{}, EndTime: t2, Distance: 150.0}, 0.0},
+		{"Zero End Time", PenaltyLap{StartTime: t1, EndTime: time.Time{}, Distance: 150.0}, 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lap.AverageSpeed()
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("PenaltyLap.AverageSpeed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
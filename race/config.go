@@ -0,0 +1,241 @@
+package race
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const configTimeLayout = "15:04:05"
+
+// RaceFormat selects how misses on the firing range are penalized.
+type RaceFormat string
+
+const (
+	// FormatSprint makes competitors ski a penalty loop for each miss.
+	FormatSprint RaceFormat = "sprint"
+	// FormatIndividual adds Config.PenaltyTime to the total time for each
+	// miss instead.
+	FormatIndividual RaceFormat = "individual"
+	// FormatPursuit starts each competitor behind the start time by their
+	// gap to the winner of an earlier race and ranks by finish order.
+	FormatPursuit RaceFormat = "pursuit"
+	// FormatMassStart starts every competitor together at Config.Start
+	// without a draw and ranks by finish order.
+	FormatMassStart RaceFormat = "mass"
+	// FormatRelay runs Config.Teams as relays: the first legs start
+	// together at Config.Start and each leg hands off to the next at its
+	// finish. Laps is the number of laps per leg.
+	FormatRelay RaceFormat = "relay"
+)
+
+// defaultPenaltyTime is the individual race's penalty per miss.
+const defaultPenaltyTime = time.Minute
+
+// defaultShots is the standard number of targets per firing range visit.
+const defaultShots = 5
+
+type Config struct {
+	Laps       int     `json:"laps"`
+	LapLen     float64 `json:"lapLen"`
+	PenaltyLen float64 `json:"penaltyLen"`
+	// FiringLines is the number of firing lines on the range; event 5
+	// names one of them.
+	FiringLines int `json:"firingLines"`
+	// RangesPerLap is the number of firing range visits expected on each
+	// lap; FiringLines if zero, for courses that visit every line per lap.
+	RangesPerLap int `json:"rangesPerLap"`
+	// Shots is the number of targets, and regular rounds, per firing
+	// range visit; five if zero.
+	Shots int `json:"shots"`
+	// SpareRounds is the number of extra rounds a competitor may load per
+	// visit, as in relays, to hit the targets left standing after the
+	// regular rounds. Misses are the targets still standing afterwards,
+	// and the rounds fired count towards the hits/shots column.
+	SpareRounds int    `json:"spareRounds"`
+	Start       string `json:"start"`
+	StartDelta  string `json:"startDelta"`
+	// LapLens, if set, gives the length of each lap in order instead of
+	// LapLen and must have Laps entries.
+	LapLens []float64 `json:"lapLens"`
+	// Checkpoints gives the distance of each intermediate checkpoint from
+	// the start of a lap, in lap order; event 12 reports passing one.
+	Checkpoints []float64 `json:"checkpoints"`
+
+	// Format is the race format; empty means FormatSprint.
+	Format RaceFormat `json:"format"`
+	// PenaltyTime is the time added per miss in the individual format,
+	// one minute if empty.
+	PenaltyTime string `json:"penaltyTime"`
+	// PreviousResults is the result table of the earlier race a pursuit
+	// is started from, relative to the config file.
+	PreviousResults string `json:"previousResults"`
+	// Teams lists the relay teams.
+	Teams []Team `json:"teams"`
+	// ShootingSequence gives the shooting position of each firing range
+	// visit in order, repeating from the start if the race has more
+	// visits, e.g. ["prone", "standing"].
+	ShootingSequence []ShootingPosition `json:"shootingSequence"`
+
+	// MaxPenaltySpeed is the fastest plausible speed on the penalty loop in
+	// m/s; penalty laps completed faster were cut short. Zero disables
+	// the check.
+	MaxPenaltySpeed float64 `json:"maxPenaltySpeed"`
+	// MissedPenaltyTime, if set, is added to the total time for each
+	// penalty loop that was skipped or cut short, and lets a competitor
+	// who skipped penalty loops continue the race.
+	MissedPenaltyTime string `json:"missedPenaltyTime"`
+
+	parsedStart             time.Time
+	parsedStartDelta        time.Duration
+	parsedMissedPenaltyTime time.Duration
+	parsedPenaltyTime       time.Duration
+	// pursuitGaps holds each competitor's start delay in a pursuit.
+	pursuitGaps map[int]time.Duration
+	// relayLegs maps each relay athlete to their team and leg.
+	relayLegs map[int]relayLeg
+	// dir is the directory relative paths in the config are resolved
+	// against.
+	dir string
+}
+
+// lapLength returns the length of lap, counted from 1.
+func (c *Config) lapLength(lap int) float64 {
+	if lap >= 1 && lap <= len(c.LapLens) {
+		return c.LapLens[lap-1]
+	}
+	return c.LapLen
+}
+
+// shootingPosition returns the position of a competitor's visit-th firing
+// range visit, counted from 0, or "" if no sequence is configured.
+func (c *Config) shootingPosition(visit int) ShootingPosition {
+	if len(c.ShootingSequence) == 0 {
+		return ""
+	}
+	return c.ShootingSequence[visit%len(c.ShootingSequence)]
+}
+
+// sharedStart reports whether competitors start together at Config.Start
+// rather than at drawn start times.
+func (c *Config) sharedStart() bool {
+	return c.Format == FormatMassStart || c.Format == FormatRelay
+}
+
+// raceTime is competitor's time at the given moment as the standings rank
+// it: from the common start in formats ranked by crossing order, from their
+// own scheduled start otherwise, plus any time penalty so far.
+func (c *Config) raceTime(competitor *Competitor, at time.Time) time.Duration {
+	start := competitor.ScheduledStartTime
+	if c.Format == FormatPursuit || c.sharedStart() {
+		start = c.parsedStart
+	}
+	return at.Sub(start) + competitor.TimePenalty
+}
+
+// LoadConfig reads the race configuration from path, choosing the format
+// by extension: YAML for ".yaml" and ".yml", TOML for ".toml" and JSON
+// otherwise. Every field is validated and every problem is reported at
+// once in a *ConfigError.
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %w", err)
+	}
+	defer file.Close()
+
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var config Config
+	problems, err := decodeConfig(bytes, filepath.Ext(path), &config)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(filepath.Dir(path), true); err != nil {
+		var invalid *ConfigError
+		if !errors.As(err, &invalid) {
+			return nil, err
+		}
+		problems.merge(invalid)
+	}
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
+	return &config, nil
+}
+
+// decodeConfig unmarshals data into config one top-level field at a time,
+// so that a field of the wrong type is reported by name in the returned
+// *ConfigError along with every other problem instead of ending the load.
+// YAML and TOML documents are converted to JSON first so that every format
+// shares the json tags. The error is non-nil only if the document cannot
+// be parsed at all.
+func decodeConfig(data []byte, ext string, config *Config) (*ConfigError, error) {
+	fields := map[string]json.RawMessage{}
+	var raw map[string]any
+	var format string
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		format = "YAML"
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing config YAML: %w", err)
+		}
+	case ".toml":
+		format = "TOML"
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing config TOML: %w", err)
+		}
+		// Unquoted times such as start = 09:30:00 are TOML local times;
+		// turn them back into the strings the other formats use.
+		for key, value := range raw {
+			if t, ok := value.(time.Time); ok {
+				layout := configTimeLayout
+				if t.Nanosecond() != 0 {
+					layout = timeLayout
+				}
+				raw[key] = t.Format(layout)
+			}
+		}
+	default:
+		if err := json.Unmarshal(data, &fields); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("error parsing config JSON: want an object, got %s", typeErr.Value)
+			}
+			return nil, fmt.Errorf("error parsing config JSON: %w", err)
+		}
+	}
+	for key, value := range raw {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %s: unsupported value %v", format, key, value)
+		}
+		fields[key] = data
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	problems := &ConfigError{}
+	for _, key := range keys {
+		field, _ := json.Marshal(map[string]json.RawMessage{key: fields[key]})
+		if err := json.Unmarshal(field, config); err != nil {
+			problems.addDecodeError(key, err)
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,350 @@
+package race
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTempConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.json")
+	err := os.WriteFile(filePath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	return filePath
+}
+
+func createTempConfigFileNamed(t *testing.T, name, content string) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	return filePath
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "YAML",
+			file: "config.yaml",
+			content: `laps: 2
+lapLen: 3300
+lapLens: [3300, 2500]
+penaltyLen: 150
+firingLines: 1
+start: 09:30:00
+startDelta: "00:00:30"
+format: relay
+teams:
+  - id: 1
+    name: North
+    legs: [1, 2]
+`,
+		},
+		{
+			name: "TOML",
+			file: "config.toml",
+			content: `laps = 2
+lapLen = 3300
+lapLens = [3300, 2500]
+penaltyLen = 150
+firingLines = 1
+start = 09:30:00
+startDelta = 00:00:30
+format = "relay"
+
+[[teams]]
+id = 1
+name = "North"
+legs = [1, 2]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadConfig(createTempConfigFileNamed(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("LoadConfig() unexpected error = %v", err)
+			}
+			want := []Team{{ID: 1, Name: "North", Legs: []int{1, 2}}}
+			if config.Laps != 2 || config.LapLen != 3300 || config.PenaltyLen != 150 || config.FiringLines != 1 ||
+				!reflect.DeepEqual(config.LapLens, []float64{3300, 2500}) || config.Format != FormatRelay ||
+				!reflect.DeepEqual(config.Teams, want) {
+				t.Errorf("LoadConfig() = %+v", config)
+			}
+			if got := config.parsedStart.Format(configTimeLayout); got != "09:30:00" {
+				t.Errorf("parsedStart = %s, want 09:30:00", got)
+			}
+			if config.parsedStartDelta != 30*time.Second {
+				t.Errorf("parsedStartDelta = %v, want 30s", config.parsedStartDelta)
+			}
+		})
+	}
+
+	t.Run("YAML Type Errors", func(t *testing.T) {
+		_, err := LoadConfig(createTempConfigFileNamed(t, "config.yaml", "laps: two\nlapLen: 3651\nstart: 09:30:00\n"))
+		want := "invalid config: laps: must be an integer, got string; startDelta: is required"
+		if err == nil || err.Error() != want {
+			t.Errorf("LoadConfig() error = %v, want %q", err, want)
+		}
+	})
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		_, err := LoadConfig(createTempConfigFileNamed(t, "config.yml", "laps: [1"))
+		if err == nil || !strings.Contains(err.Error(), "error parsing config YAML:") {
+			t.Errorf("LoadConfig() error = %v, want a YAML parse error", err)
+		}
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	validConfigContent := `{
+		"laps": 3,
+		"lapLen": 1500.0,
+		"penaltyLen": 150.0,
+		"firingLines": 10,
+		"start": "12:00:00",
+		"startDelta": "00:00:30.000"
+	}`
+	expectedStartTime, _ := time.Parse(configTimeLayout, "12:00:00")
+	expectedStartDelta, _ := ParseDuration("00:00:30.000")
+
+	expectedConfig := &Config{
+		Laps:             3,
+		LapLen:           1500.0,
+		PenaltyLen:       150.0,
+		FiringLines:      10,
+		Start:            "12:00:00",
+		StartDelta:       "00:00:30.000",
+		parsedStart:      expectedStartTime,
+		parsedStartDelta: expectedStartDelta,
+	}
+
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T) string
+		want        *Config
+		wantErrStr  string
+		checkParsed bool
+	}{
+		{
+			name: "Valid Config",
+			setup: func(t *testing.T) string {
+				return createTempConfigFile(t, validConfigContent)
+			},
+			want:        expectedConfig,
+			wantErrStr:  "",
+			checkParsed: true,
+		},
+		{
+			name: "File Not Found",
+			setup: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "nonexistent.json")
+			},
+			want:       nil,
+			wantErrStr: "error opening config file:",
+		},
+		{
+			name: "Invalid JSON",
+			setup: func(t *testing.T) string {
+				return createTempConfigFile(t, `{"laps": 3, "lapLen": 1500.0,`)
+			},
+			want:       nil,
+			wantErrStr: "error parsing config JSON:",
+		},
+		{
+			name: "Invalid Start Time Format",
+			setup: func(t *testing.T) string {
+				invalidTimeContent := strings.Replace(validConfigContent, `"12:00:00"`, `"12-00-00"`, 1)
+				return createTempConfigFile(t, invalidTimeContent)
+			},
+			want:       nil,
+			wantErrStr: "start: invalid time '12-00-00'",
+		},
+		{
+			name: "Invalid Start Delta Format",
+			setup: func(t *testing.T) string {
+				invalidDeltaContent := strings.Replace(validConfigContent, `"00:00:30.000"`, `"invalid"`, 1)
+				return createTempConfigFile(t, invalidDeltaContent)
+			},
+			want:       nil,
+			wantErrStr: "startDelta: invalid duration 'invalid'",
+		},
+		{
+			name: "Pursuit Without Previous Results",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"format": "pursuit", "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "previousResults: is required in the pursuit format",
+		},
+		{
+			name: "Relay Without Teams",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"format": "relay", "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "relay format requires teams",
+		},
+		{
+			name: "Unknown Shooting Position",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"shootingSequence": ["prone", "kneeling"], "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "shootingSequence[1]: unknown shooting position 'kneeling'",
+		},
+		{
+			name: "Lap Lengths Do Not Match Laps",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"lapLens": [3300, 2500], "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "lapLens: has 2 entries, want one per lap (3)",
+		},
+		{
+			name: "Checkpoints Out Of Order",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"checkpoints": [1200, 800], "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "checkpoints[1]: must be further than checkpoint 1 at 1200, got 800",
+		},
+		{
+			name: "Checkpoint Beyond Lap",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"lapLens": [3651, 2500, 3651], "checkpoints": [3000], "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "checkpoints[0]: must be within lap 2 of 2500, got 3000",
+		},
+		{
+			name: "Negative Ranges Per Lap",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"rangesPerLap": -1, "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "rangesPerLap: must not be negative, got -1",
+		},
+		{
+			name: "Negative Shots",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"shots": -1, "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "shots: must not be negative",
+		},
+		{
+			name: "Unknown Race Format",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"format": "marathon", "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "unknown race format 'marathon'",
+		},
+		{
+			name: "Invalid Missed Penalty Time",
+			setup: func(t *testing.T) string {
+				content := strings.Replace(validConfigContent, `"startDelta"`, `"missedPenaltyTime": "soon", "startDelta"`, 1)
+				return createTempConfigFile(t, content)
+			},
+			want:       nil,
+			wantErrStr: "missedPenaltyTime: invalid duration 'soon'",
+		},
+		{
+			name: "Missing Field (Laps)",
+			setup: func(t *testing.T) string {
+				missingFieldContent := `{
+					"lapLen": 1500.0,
+					"penaltyLen": 150.0,
+					"firingLines": 10,
+					"start": "12:00:00",
+					"startDelta": "00:00:30.000"
+				}`
+				return createTempConfigFile(t, missingFieldContent)
+			},
+			want:       nil,
+			wantErrStr: "laps: must be positive, got 0",
+		},
+		{
+			name: "Every Problem At Once",
+			setup: func(t *testing.T) string {
+				return createTempConfigFile(t, `{"laps": -1, "lapLen": 0, "firingLines": -2, "start": "noon"}`)
+			},
+			want: nil,
+			wantErrStr: "invalid config: laps: must be positive, got -1; lapLen: must be positive, got 0; " +
+				"firingLines: must not be negative, got -2; start: invalid time 'noon', want HH:MM:SS; startDelta: is required",
+		},
+		{
+			name: "Type Errors With Other Problems",
+			setup: func(t *testing.T) string {
+				return createTempConfigFile(t, `{"laps": "two", "lapLen": 3651, "lapLens": [3300, "far"], "firingLines": -1, "start": "09:30:00", "startDelta": "00:00:30"}`)
+			},
+			want: nil,
+			wantErrStr: "invalid config: lapLens[1]: must be a number, got string; laps: must be an integer, got string; " +
+				"firingLines: must not be negative, got -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := tt.setup(t)
+			got, err := LoadConfig(configPath)
+
+			if tt.wantErrStr != "" {
+				if err == nil {
+					t.Errorf("LoadConfig() expected error containing %q, but got nil", tt.wantErrStr)
+				} else if !strings.Contains(err.Error(), tt.wantErrStr) {
+					t.Errorf("LoadConfig() error = %v, want error containing %q", err, tt.wantErrStr)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("LoadConfig() unexpected error = %v", err)
+				}
+				if got == nil && tt.want != nil {
+					t.Errorf("LoadConfig() got nil, want non-nil")
+					return
+				}
+				if got != nil && tt.want == nil {
+					t.Errorf("LoadConfig() got non-nil, want nil")
+					return
+				}
+				if got != nil && tt.want != nil {
+					if got.Laps != tt.want.Laps || got.LapLen != tt.want.LapLen ||
+						got.PenaltyLen != tt.want.PenaltyLen || got.FiringLines != tt.want.FiringLines ||
+						got.Start != tt.want.Start || got.StartDelta != tt.want.StartDelta {
+						t.Errorf("LoadConfig() basic fields mismatch. Got %+v, want %+v", got, tt.want)
+					}
+					if tt.checkParsed {
+						if !got.parsedStart.Equal(tt.want.parsedStart) {
+							t.Errorf("LoadConfig() parsedStart mismatch. Got %v, want %v", got.parsedStart, tt.want.parsedStart)
+						}
+						if got.parsedStartDelta != tt.want.parsedStartDelta {
+							t.Errorf("LoadConfig() parsedStartDelta mismatch. Got %v, want %v", got.parsedStartDelta, tt.want.parsedStartDelta)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package race
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with one config field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ConfigError lists every problem found in a config file.
+type ConfigError struct {
+	Problems []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.Error())
+	}
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+func (e *ConfigError) add(field, format string, args ...any) {
+	e.Problems = append(e.Problems, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// addDecodeError records err from decoding the top-level field key, naming
+// the offending value, e.g. "teams[0].legs[1]: must be an integer, got
+// string".
+func (e *ConfigError) addDecodeError(key string, err error) {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		e.add(key, "%s", strings.TrimPrefix(err.Error(), "json: "))
+		return
+	}
+	field := key
+	if typeErr.Field != "" {
+		field = ""
+		for _, part := range strings.Split(typeErr.Field, ".") {
+			if _, err := strconv.Atoi(part); err == nil {
+				field += "[" + part + "]"
+			} else if field == "" {
+				field = part
+			} else {
+				field += "." + part
+			}
+		}
+	}
+	e.add(field, "must be %s, got %s", kindName(typeErr.Type), typeErr.Value)
+}
+
+// kindName describes the config values that decode into t.
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// merge appends the problems in other, leaving out those with a top-level
+// field that already failed to decode, which would only restate it.
+func (e *ConfigError) merge(other *ConfigError) {
+	failed := map[string]bool{}
+	for _, problem := range e.Problems {
+		failed[topLevelField(problem.Field)] = true
+	}
+	for _, problem := range other.Problems {
+		if !failed[topLevelField(problem.Field)] {
+			e.Problems = append(e.Problems, problem)
+		}
+	}
+}
+
+// topLevelField returns the config key a problem's field is under, e.g.
+// "teams" for "teams[0].legs[1]".
+func topLevelField(field string) string {
+	if i := strings.IndexAny(field, "[."); i >= 0 {
+		field = field[:i]
+	}
+	return strings.ToLower(field)
+}
+
+// validate checks every field, fills in defaults and parses the start time,
+// durations, previous results and relay teams. Relative paths are resolved
+// against dir. Previous results are only read if loadResults is set.
+func (c *Config) validate(dir string, loadResults bool) error {
+	problems := &ConfigError{}
+	c.dir = dir
+
+	if c.Laps <= 0 {
+		problems.add("laps", "must be positive, got %d", c.Laps)
+	}
+	if len(c.LapLens) == 0 {
+		if c.LapLen <= 0 {
+			problems.add("lapLen", "must be positive, got %g", c.LapLen)
+		}
+	} else {
+		if len(c.LapLens) != c.Laps {
+			problems.add("lapLens", "has %d entries, want one per lap (%d)", len(c.LapLens), c.Laps)
+		}
+		for i, length := range c.LapLens {
+			if length <= 0 {
+				problems.add(fmt.Sprintf("lapLens[%d]", i), "must be positive, got %g", length)
+			}
+		}
+	}
+	for i, distance := range c.Checkpoints {
+		field := fmt.Sprintf("checkpoints[%d]", i)
+		switch {
+		case distance <= 0:
+			problems.add(field, "must be positive, got %g", distance)
+		case i > 0 && distance <= c.Checkpoints[i-1]:
+			problems.add(field, "must be further than checkpoint %d at %g, got %g", i, c.Checkpoints[i-1], distance)
+		}
+		for lap := 1; lap <= c.Laps; lap++ {
+			if length := c.lapLength(lap); length > 0 && distance >= length {
+				problems.add(field, "must be within lap %d of %g, got %g", lap, length, distance)
+				break
+			}
+		}
+	}
+	if c.PenaltyLen < 0 {
+		problems.add("penaltyLen", "must not be negative, got %g", c.PenaltyLen)
+	}
+	if c.FiringLines < 0 {
+		problems.add("firingLines", "must not be negative, got %d", c.FiringLines)
+	}
+	if c.RangesPerLap < 0 {
+		problems.add("rangesPerLap", "must not be negative, got %d", c.RangesPerLap)
+	} else if c.RangesPerLap == 0 {
+		c.RangesPerLap = c.FiringLines
+	}
+	if c.Shots < 0 {
+		problems.add("shots", "must not be negative, got %d", c.Shots)
+	} else if c.Shots == 0 {
+		c.Shots = defaultShots
+	}
+	if c.SpareRounds < 0 {
+		problems.add("spareRounds", "must not be negative, got %d", c.SpareRounds)
+	}
+	if c.MaxPenaltySpeed < 0 {
+		problems.add("maxPenaltySpeed", "must not be negative, got %g", c.MaxPenaltySpeed)
+	}
+
+	var err error
+	if c.Start == "" {
+		problems.add("start", "is required")
+	} else if c.parsedStart, err = time.Parse(configTimeLayout, c.Start); err != nil {
+		problems.add("start", "invalid time '%s', want HH:MM:SS", c.Start)
+	}
+	if c.StartDelta == "" {
+		problems.add("startDelta", "is required")
+	} else if c.parsedStartDelta, err = ParseDuration(c.StartDelta); err != nil {
+		problems.add("startDelta", "invalid duration '%s': %v", c.StartDelta, err)
+	}
+
+	c.parsedPenaltyTime = defaultPenaltyTime
+	if c.PenaltyTime != "" {
+		if c.parsedPenaltyTime, err = ParseDuration(c.PenaltyTime); err != nil {
+			problems.add("penaltyTime", "invalid duration '%s': %v", c.PenaltyTime, err)
+		}
+	}
+	if c.MissedPenaltyTime != "" {
+		if c.parsedMissedPenaltyTime, err = ParseDuration(c.MissedPenaltyTime); err != nil {
+			problems.add("missedPenaltyTime", "invalid duration '%s': %v", c.MissedPenaltyTime, err)
+		}
+	}
+
+	for i, position := range c.ShootingSequence {
+		if position != PositionProne && position != PositionStanding {
+			problems.add(fmt.Sprintf("shootingSequence[%d]", i), "unknown shooting position '%s'", position)
+		}
+	}
+
+	switch c.Format {
+	case "":
+		c.Format = FormatSprint
+	case FormatSprint, FormatIndividual, FormatMassStart:
+	case FormatPursuit:
+		if c.PreviousResults == "" {
+			problems.add("previousResults", "is required in the pursuit format")
+			break
+		}
+		if !loadResults {
+			break
+		}
+		resultsPath := c.PreviousResults
+		if !filepath.IsAbs(resultsPath) {
+			resultsPath = filepath.Join(dir, resultsPath)
+		}
+		if results, err := LoadPreviousResults(resultsPath); err != nil {
+			problems.add("previousResults", "%v", err)
+		} else {
+			c.pursuitGaps = pursuitGaps(results)
+		}
+	case FormatRelay:
+		if c.relayLegs, err = relayLegs(c.Teams); err != nil {
+			problems.add("teams", "%v", err)
+		}
+	default:
+		problems.add("format", "unknown race format '%s'", c.Format)
+	}
+
+	if len(problems.Problems) > 0 {
+		return problems
+	}
+	return nil
+}
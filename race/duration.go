@@ -0,0 +1,70 @@
+package race
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration parses a "HH:MM:SS" or "HH:MM:SS.sss" duration.
+func ParseDuration(durationStr string) (time.Duration, error) {
+	parts := strings.Split(durationStr, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid duration format: %s", durationStr)
+	}
+
+	secsParts := strings.Split(parts[2], ".")
+	var d time.Duration
+	var err error
+
+	h, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	s, err := strconv.Atoi(secsParts[0])
+	if err != nil {
+		return 0, err
+	}
+
+	d = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+
+	if len(secsParts) == 2 {
+		ms, err := strconv.Atoi(secsParts[1])
+		if err != nil {
+			return 0, err
+		}
+		msStr := secsParts[1]
+		for len(msStr) < 3 {
+			msStr += "0"
+		}
+		ms, err = strconv.Atoi(msStr)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(ms) * time.Millisecond
+	}
+
+	return d, nil
+}
+
+// FormatDuration formats d as "HH:MM:SS.sss". Negative durations are
+// formatted by their absolute value.
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+
+	totalSeconds := int64(d.Seconds())
+	milliseconds := d.Milliseconds() % 1000
+
+	hours := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	seconds := totalSeconds % 60
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, seconds, milliseconds)
+}
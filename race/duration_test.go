@@ -0,0 +1,69 @@
+package race
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      time.Duration
+		expectErr bool
+	}{
+		{"Valid Full", "01:02:03.456", time.Hour + 2*time.Minute + 3*time.Second + 456*time.Millisecond, false},
+		{"Valid No Millis", "00:10:30", 10*time.Minute + 30*time.Second, false},
+		{"Valid Short Millis 1", "00:00:01.5", 1*time.Second + 500*time.Millisecond, false},
+		{"Valid Short Millis 2", "00:00:02.05", 2*time.Second + 50*time.Millisecond, false},
+		{"Zero Duration", "00:00:00.000", 0, false},
+		{"Invalid Format Colon", "01-02-03.456", 0, true},
+		{"Invalid Format Parts", "01:02", 0, true},
+		{"Invalid Format Too Many Parts", "01:02:03:04", 0, true},
+		{"Invalid Hour", "xx:02:03.456", 0, true},
+		{"Invalid Minute", "01:xx:03.456", 0, true},
+		{"Invalid Second", "01:02:xx.456", 0, true},
+		{"Invalid Millis", "01:02:03.xxx", 0, true},
+		{"Empty String", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+
+			if (err != nil) != tt.expectErr {
+				t.Errorf("ParseDuration(%q) error = %v, expectErr %v", tt.input, err, tt.expectErr)
+				return
+			}
+			if !tt.expectErr && got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		input time.Duration
+		want  string
+	}{
+		{"Zero", 0, "00:00:00.000"},
+		{"Millis Only", 123 * time.Millisecond, "00:00:00.123"},
+		{"Seconds Only", 45 * time.Second, "00:00:45.000"},
+		{"Minutes Only", 15 * time.Minute, "00:15:00.000"},
+		{"Hours Only", 2 * time.Hour, "02:00:00.000"},
+		{"Full", 1*time.Hour + 23*time.Minute + 45*time.Second + 678*time.Millisecond, "01:23:45.678"},
+		{"Short Millis", 5*time.Second + 50*time.Millisecond, "00:00:05.050"}, // Needs padding
+		{"Long Duration", 25*time.Hour + 1*time.Minute + 1*time.Second + 1*time.Millisecond, "25:01:01.001"},
+		{"Negative Duration", -(1*time.Minute + 30*time.Second), "00:01:30.000"}, // Should format as positive
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDuration(tt.input); got != tt.want {
+				t.Errorf("FormatDuration(%v) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
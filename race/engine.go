@@ -0,0 +1,699 @@
+package race
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RaceEngine applies incoming events to the competitors' state machines and
+// produces the outgoing log. It is not safe for concurrent use.
+type RaceEngine struct {
+	config      *Config
+	competitors map[int]*Competitor
+	// order keeps competitors in registration order so that sweeps over
+	// all competitors produce a stable log.
+	order []*Competitor
+
+	lastProcessedTime time.Time
+	lastEventTime     time.Time
+	violations        []Violation
+	// finished counts finishers to set Competitor.FinishOrder.
+	finished int
+	// passings holds everyone who has passed each timing point so far, to
+	// rank the next one to pass it and to rank them all at the finish.
+	passings map[timingPoint][]passing
+}
+
+// timingPoint is a point of the course where competitors are ranked: the
+// end of lap N, or the exit of their Nth firing range visit, on a relay
+// leg (0 outside relays).
+type timingPoint struct {
+	leg    int
+	onLap  bool
+	number int
+}
+
+// passing is a competitor passing a timing point with the given race time.
+type passing struct {
+	competitor *Competitor
+	raceTime   time.Duration
+}
+
+func NewRaceEngine(config *Config) *RaceEngine {
+	return &RaceEngine{
+		config:      config,
+		competitors: make(map[int]*Competitor),
+		passings:    make(map[timingPoint][]passing),
+	}
+}
+
+// LogEntry is a single line of the outgoing log. Event is set when the line
+// corresponds to one of the spec's outgoing events (32, 33).
+type LogEntry struct {
+	Time         time.Time
+	Message      string
+	Event        *Event
+	CompetitorID int
+	// Status is the competitor's status once the event that produced the
+	// line has been applied.
+	Status CompetitorStatus
+}
+
+func (l LogEntry) String() string {
+	return fmt.Sprintf("%s %s", l.Time.Format(eventTimeLayout), l.Message)
+}
+
+func logEntry(t time.Time, competitorID int, msg string) LogEntry {
+	return LogEntry{Time: t, Message: msg, CompetitorID: competitorID}
+}
+
+func outgoingEntry(t time.Time, eventID, competitorID int, msg string) LogEntry {
+	event := &Event{Time: t, ID: eventID, CompetitorID: competitorID}
+	event.RawLine = event.String()
+	return LogEntry{Time: t, Message: msg, Event: event, CompetitorID: competitorID}
+}
+
+// withStatus sets the Status of each entry to its competitor's current
+// status.
+func (e *RaceEngine) withStatus(entries []LogEntry) []LogEntry {
+	for i := range entries {
+		if c, ok := e.competitors[entries[i].CompetitorID]; ok {
+			entries[i].Status = c.Status
+		}
+	}
+	return entries
+}
+
+// Apply processes a single incoming event and returns the log entries it
+// produced. Events that do not fit the competitor's current state are
+// ignored, recorded as violations and returned as the error; outgoing
+// events fed back in are ignored silently. A non-nil error describes an
+// event that could not be applied at all; any entries returned alongside
+// it are still valid. Events that are applied but flagged only add to
+// Violations.
+func (e *RaceEngine) Apply(event *Event) ([]LogEntry, error) {
+	out, err := e.apply(event)
+	return e.withStatus(out), err
+}
+
+func (e *RaceEngine) apply(event *Event) ([]LogEntry, error) {
+	if event.IsOutgoing() {
+		return nil, nil
+	}
+
+	if !e.lastEventTime.IsZero() && event.Time.Before(e.lastEventTime) {
+		reason := fmt.Sprintf("event time is before the previous event at %s", e.lastEventTime.Format(eventTimeLayout))
+		e.flag(event, e.competitors[event.CompetitorID], CodeBadTime, reason)
+	} else {
+		e.lastEventTime = event.Time
+	}
+
+	out := e.disqualifyLateStarters(event.Time)
+
+	competitor, exists := e.competitors[event.CompetitorID]
+
+	if event.ID == EventRegistered {
+		leg, inRelay := e.config.relayLegs[event.CompetitorID]
+		if !exists && e.config.Format == FormatRelay && !inRelay {
+			return out, e.reject(event, nil, CodeWrongState, "competitor is not on any relay team")
+		}
+		if !exists {
+			competitor = &Competitor{
+				ID:                   event.CompetitorID,
+				Status:               StatusRegistered,
+				LapsCompleted:        []Lap{},
+				PenaltyLapsCompleted: []PenaltyLap{},
+				FiringRangeVisits:    []FiringRangeVisit{},
+				CurrentLapNumber:     0,
+			}
+			e.competitors[event.CompetitorID] = competitor
+			e.order = append(e.order, competitor)
+			msg := fmt.Sprintf("The competitor(%d) registered", event.CompetitorID)
+			out = append(out, logEntry(event.Time, event.CompetitorID, msg))
+			if gap, ok := e.config.pursuitGaps[event.CompetitorID]; ok && e.config.Format == FormatPursuit {
+				competitor.ScheduledStartTime = e.config.parsedStart.Add(gap)
+				competitor.Status = StatusScheduled
+				msg = fmt.Sprintf("The start time for the competitor(%d) was set by pursuit to %s",
+					event.CompetitorID, competitor.ScheduledStartTime.Format(timeLayout))
+				out = append(out, logEntry(event.Time, event.CompetitorID, msg))
+			}
+			if inRelay {
+				competitor.Team = leg.team.ID
+				competitor.Leg = leg.leg
+			}
+			// Relay athletes after the first leg wait for their hand-off.
+			if e.config.Format == FormatMassStart || inRelay && leg.leg == 1 {
+				competitor.ScheduledStartTime = e.config.parsedStart
+				competitor.Status = StatusScheduled
+				setBy := "mass start"
+				if e.config.Format == FormatRelay {
+					setBy = "relay start"
+				}
+				msg = fmt.Sprintf("The start time for the competitor(%d) was set by %s to %s",
+					event.CompetitorID, setBy, competitor.ScheduledStartTime.Format(timeLayout))
+				out = append(out, logEntry(event.Time, event.CompetitorID, msg))
+			}
+		} else {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is already registered")
+		}
+	} else if !exists {
+		return out, e.reject(event, nil, CodeUnknownCompetitor, "unknown competitor")
+	} else if competitor.Status.IsFinal() {
+		return out, e.reject(event, competitor, CodeAlreadyFinished, "competitor is out of the race")
+	}
+
+	competitor.LastEventTime = event.Time
+
+	onCourse := event.ID >= EventOnFiringRange && event.ID <= EventEndedMainLap || event.ID == EventCheckpoint
+	if e.config.sharedStart() && onCourse &&
+		(competitor.Status == StatusScheduled || competitor.Status == StatusOnStartLine) &&
+		!event.Time.Before(competitor.ScheduledStartTime) {
+		// Event 4 is optional in a mass start: reaching the course means
+		// the competitor went off with the gun.
+		out = append(out, e.startCompetitor(competitor, competitor.ScheduledStartTime, event.Time))
+	}
+
+	logMsg := ""
+
+	switch event.ID {
+	case EventStartTimeSet:
+		if e.config.sharedStart() {
+			return out, e.reject(event, competitor, CodeWrongState, fmt.Sprintf("there is no draw in the %s format", e.config.Format))
+		}
+		if _, ok := e.config.pursuitGaps[competitor.ID]; ok && e.config.Format == FormatPursuit {
+			return out, e.reject(event, competitor, CodeWrongState, "start time is set from the previous results")
+		}
+		if competitor.Status != StatusRegistered && competitor.Status != StatusScheduled {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor has already started")
+		}
+		if len(event.ExtraParams) < 1 {
+			return out, e.reject(event, competitor, CodeMissingParam, "missing start time")
+		}
+		startTimeStr := event.ExtraParams[0]
+		scheduledTime, err := time.Parse(timeLayout, startTimeStr)
+		if err != nil {
+			return out, e.reject(event, competitor, CodeBadTime, fmt.Sprintf("invalid start time format '%s': %v", startTimeStr, err))
+		}
+		baseDate := e.config.parsedStart.Truncate(24 * time.Hour)
+		competitor.ScheduledStartTime = baseDate.Add(time.Duration(scheduledTime.Hour())*time.Hour + time.Duration(scheduledTime.Minute())*time.Minute + time.Duration(scheduledTime.Second())*time.Second + time.Duration(scheduledTime.Nanosecond()))
+
+		competitor.Status = StatusScheduled
+		logMsg = fmt.Sprintf("The start time for the competitor(%d) was set by a draw to %s", event.CompetitorID, startTimeStr)
+
+	case EventOnStartLine:
+		if competitor.Status != StatusScheduled {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor has no start time drawn")
+		}
+		competitor.Status = StatusOnStartLine
+		logMsg = fmt.Sprintf("The competitor(%d) is on the start line", event.CompetitorID)
+
+	case EventStarted:
+		if e.config.sharedStart() {
+			if competitor.Status == StatusRegistered {
+				return out, e.reject(event, competitor, CodeWrongState, "competitor is waiting for the relay hand-off")
+			}
+			if event.Time.Before(competitor.ScheduledStartTime) {
+				return out, e.reject(event, competitor, CodeWrongState, "competitor started before the mass start")
+			}
+			if competitor.Status == StatusScheduled || competitor.Status == StatusOnStartLine {
+				out = append(out, e.startCompetitor(competitor, competitor.ScheduledStartTime, event.Time))
+			}
+			break
+		}
+		allowedStartWindowEnd := competitor.ScheduledStartTime.Add(e.config.parsedStartDelta)
+		if event.Time.After(allowedStartWindowEnd) && !competitor.ScheduledStartTime.IsZero() {
+			if competitor.Status != StatusNotStarted {
+				competitor.Status = StatusNotStarted
+				competitor.FinishTime = event.Time
+				msg := fmt.Sprintf("The competitor(%d) is disqualified (Started too late)", event.CompetitorID)
+				out = append(out, logEntry(event.Time, event.CompetitorID, msg))
+				out = append(out, outgoingEntry(event.Time, EventDisqualified, event.CompetitorID, fmt.Sprintf("The competitor(%d) is disqualified", event.CompetitorID)))
+			}
+			return out, nil
+		}
+
+		if competitor.Status != StatusOnStartLine && competitor.Status != StatusScheduled {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not waiting to start")
+		}
+		out = append(out, e.startCompetitor(competitor, event.Time, event.Time))
+
+	case EventOnFiringRange:
+		if competitor.Status != StatusStarted && competitor.Status != StatusOnLap {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on a lap")
+		}
+		if competitor.LastMisses > 0 {
+			e.missedPenalty(event, competitor, competitor.LastMisses, CodeMissingPenalty,
+				fmt.Sprintf("%d penalty laps were not served before the next firing range", competitor.LastMisses))
+			competitor.LastMisses = 0
+		}
+		competitor.Status = StatusOnRange
+		rangeNumStr := "unknown"
+		if len(event.ExtraParams) > 0 {
+			rangeNumStr = event.ExtraParams[0]
+		}
+		competitor.CurrentRangeVisit = &FiringRangeVisit{
+			EnterTime:   event.Time,
+			Shots:       e.config.Shots,
+			SpareRounds: e.config.SpareRounds,
+			Lap:         competitor.CurrentLapNumber,
+			FiringLine:  e.checkFiringLine(event, competitor),
+			Position:    e.config.shootingPosition(len(competitor.FiringRangeVisits)),
+		}
+		competitor.CurrentRangeHits = 0 // Reset hits counter for this visit
+		logMsg = fmt.Sprintf("The competitor(%d) is on the firing range(%s)", event.CompetitorID, rangeNumStr)
+
+	case EventTargetHit:
+		if competitor.Status != StatusOnRange || competitor.CurrentRangeVisit == nil {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on the firing range")
+		}
+		visit := competitor.CurrentRangeVisit
+		if len(event.ExtraParams) < 1 {
+			return out, e.reject(event, competitor, CodeMissingParam, "missing target number")
+		}
+		target, err := strconv.Atoi(event.ExtraParams[0])
+		if err != nil || target < 1 || target > visit.Shots {
+			return out, e.reject(event, competitor, CodeBadTarget, fmt.Sprintf("target '%s' is not in 1..%d", event.ExtraParams[0], visit.Shots))
+		}
+		if visit.IsHit(target) {
+			return out, e.reject(event, competitor, CodeDuplicateHit, fmt.Sprintf("target %d was already hit on this visit", target))
+		}
+		visit.HitTargets = append(visit.HitTargets, target)
+		competitor.CurrentRangeHits++
+		logMsg = fmt.Sprintf("The target(%d) has been hit by competitor(%d)", target, event.CompetitorID)
+
+	case EventLeftFiringRange:
+		if competitor.Status != StatusOnRange || competitor.CurrentRangeVisit == nil {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on the firing range")
+		}
+		competitor.Status = StatusOnLap
+		competitor.CurrentRangeVisit.ExitTime = event.Time
+		competitor.CurrentRangeVisit.Hits = competitor.CurrentRangeHits
+		competitor.CurrentRangeVisit.RoundsFired = e.roundsFired(event, competitor, competitor.CurrentRangeVisit)
+		competitor.TotalHits += competitor.CurrentRangeVisit.Hits
+		competitor.TotalShots += competitor.CurrentRangeVisit.RoundsFired
+		competitor.LastMisses = competitor.CurrentRangeVisit.Shots - competitor.CurrentRangeVisit.Hits
+		if e.config.Format == FormatIndividual {
+			// Misses cost penalty time rather than penalty loops.
+			competitor.TimePenalty += time.Duration(competitor.LastMisses) * e.config.parsedPenaltyTime
+			competitor.LastMisses = 0
+		}
+		competitor.CurrentRangeVisit.Rank = e.rank(competitor, timingPoint{competitor.Leg, false, len(competitor.FiringRangeVisits) + 1}, event.Time)
+		competitor.FiringRangeVisits = append(competitor.FiringRangeVisits, *competitor.CurrentRangeVisit)
+		competitor.CurrentRangeVisit = nil
+		logMsg = fmt.Sprintf("The competitor(%d) left the firing range", event.CompetitorID)
+
+	case EventEnteredPenalty:
+		// Should happen after leaving range with misses
+		if competitor.Status != StatusOnLap && competitor.Status != StatusStarted {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on a lap")
+		}
+		if competitor.LastMisses <= 0 {
+			return out, e.reject(event, competitor, CodeWrongState, "no misses to serve penalty laps for")
+		}
+		competitor.Status = StatusInPenalty
+		competitor.CurrentPenaltyStart = event.Time
+		competitor.CurrentPenaltyDist = float64(competitor.LastMisses) * e.config.PenaltyLen
+		logMsg = fmt.Sprintf("The competitor(%d) entered the penalty laps", event.CompetitorID)
+
+	case EventLeftPenalty:
+		if competitor.Status != StatusInPenalty {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not in the penalty laps")
+		}
+		competitor.Status = StatusOnLap
+		penalty := PenaltyLap{
+			StartTime: competitor.CurrentPenaltyStart,
+			EndTime:   event.Time,
+			Distance:  competitor.CurrentPenaltyDist,
+			Misses:    competitor.LastMisses,
+		}
+		e.verifyPenaltyLap(event, competitor, penalty)
+		competitor.PenaltyLapsCompleted = append(competitor.PenaltyLapsCompleted, penalty)
+		competitor.CurrentPenaltyStart = time.Time{}
+		competitor.CurrentPenaltyDist = 0
+		competitor.LastMisses = 0
+		logMsg = fmt.Sprintf("The competitor(%d) left the penalty laps", event.CompetitorID)
+
+	case EventCheckpoint:
+		if competitor.Status != StatusOnLap && competitor.Status != StatusStarted {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on a lap")
+		}
+		if len(event.ExtraParams) < 1 {
+			return out, e.reject(event, competitor, CodeMissingParam, "missing checkpoint number")
+		}
+		checkpoint, err := strconv.Atoi(event.ExtraParams[0])
+		if err != nil || checkpoint < 1 || checkpoint > len(e.config.Checkpoints) {
+			return out, e.reject(event, competitor, CodeBadCheckpoint, fmt.Sprintf("checkpoint '%s' is not in 1..%d", event.ExtraParams[0], len(e.config.Checkpoints)))
+		}
+		if n := len(competitor.CurrentSplits); n > 0 && competitor.CurrentSplits[n-1].Checkpoint >= checkpoint {
+			return out, e.reject(event, competitor, CodeBadCheckpoint, fmt.Sprintf("checkpoint %d was already passed on lap %d", checkpoint, competitor.CurrentLapNumber))
+		}
+		competitor.CurrentSplits = append(competitor.CurrentSplits, Split{
+			Checkpoint: checkpoint,
+			Time:       event.Time,
+			Distance:   e.config.Checkpoints[checkpoint-1],
+		})
+		logMsg = fmt.Sprintf("The competitor(%d) passed the checkpoint(%d)", event.CompetitorID, checkpoint)
+
+	case EventEndedMainLap:
+		if competitor.Status != StatusOnLap && competitor.Status != StatusStarted {
+			return out, e.reject(event, competitor, CodeWrongState, "competitor is not on a lap")
+		}
+		if competitor.LastMisses > 0 {
+			reason := fmt.Sprintf("%d penalty laps were not served", competitor.LastMisses)
+			if e.config.parsedMissedPenaltyTime == 0 {
+				return out, e.reject(event, competitor, CodeMissingPenalty, reason)
+			}
+			e.missedPenalty(event, competitor, competitor.LastMisses, CodeMissingPenalty, reason)
+			competitor.LastMisses = 0
+		}
+
+		e.checkRangeVisits(event, competitor)
+
+		lap := Lap{
+			Number:    competitor.CurrentLapNumber,
+			StartTime: competitor.CurrentLapStart,
+			EndTime:   event.Time,
+			Distance:  e.config.lapLength(competitor.CurrentLapNumber),
+			Splits:    competitor.CurrentSplits,
+		}
+		finishing := competitor.CurrentLapNumber == e.config.Laps
+		if finishing {
+			// Set before ranking the finish so photo finishes split by it.
+			e.finished++
+			competitor.FinishOrder = e.finished
+		}
+		lap.Rank = e.rank(competitor, timingPoint{competitor.Leg, true, lap.Number}, event.Time)
+		competitor.LapsCompleted = append(competitor.LapsCompleted, lap)
+		competitor.CurrentSplits = nil
+		out = append(out, logEntry(event.Time, event.CompetitorID, fmt.Sprintf("The competitor(%d) ended the main lap", event.CompetitorID)))
+
+		if finishing {
+			competitor.Status = StatusFinished
+			competitor.FinishTime = event.Time
+			out = append(out, outgoingEntry(event.Time, EventFinished, event.CompetitorID, fmt.Sprintf("The competitor(%d) has finished", event.CompetitorID)))
+			out = append(out, e.handOff(event, competitor)...)
+		} else {
+			competitor.CurrentLapNumber++
+			competitor.CurrentLapStart = event.Time
+			competitor.Status = StatusOnLap
+		}
+
+	case EventCannotContinue:
+		competitor.Status = StatusNotFinished
+		competitor.FinishTime = event.Time
+		if len(event.ExtraParams) > 0 {
+			competitor.Comment = event.ExtraParams[0]
+			logMsg = fmt.Sprintf("The competitor(%d) can`t continue: %s", event.CompetitorID, competitor.Comment)
+		} else {
+			logMsg = fmt.Sprintf("The competitor(%d) can`t continue", event.CompetitorID)
+		}
+
+	case EventRegistered:
+		// Handled above.
+
+	default:
+		return out, e.reject(event, competitor, CodeUnknownEvent, "unknown event ID")
+	}
+
+	if logMsg != "" {
+		out = append(out, logEntry(event.Time, event.CompetitorID, logMsg))
+	}
+
+	e.lastProcessedTime = event.Time
+	return out, nil
+}
+
+// handOff starts the next relay leg of competitor's team when competitor
+// finishes their leg.
+func (e *RaceEngine) handOff(event *Event, competitor *Competitor) []LogEntry {
+	leg, ok := e.config.relayLegs[competitor.ID]
+	if !ok || e.config.Format != FormatRelay {
+		return nil
+	}
+	nextID, ok := leg.next()
+	if !ok {
+		return nil
+	}
+	next, exists := e.competitors[nextID]
+	if !exists || next.Status != StatusRegistered {
+		e.flag(event, competitor, CodeWrongState, fmt.Sprintf("competitor(%d) is not waiting for the hand-off", nextID))
+		return nil
+	}
+	next.ScheduledStartTime = event.Time
+	msg := fmt.Sprintf("The competitor(%d) handed off to the competitor(%d)", competitor.ID, nextID)
+	return []LogEntry{logEntry(event.Time, competitor.ID, msg), e.startCompetitor(next, event.Time, event.Time)}
+}
+
+// startCompetitor sends competitor off on the first lap at start. The log
+// entry is stamped with at, the time of the event that started them, which
+// is later than start when a shared start is only learned of from the
+// competitor's first event on the course.
+func (e *RaceEngine) startCompetitor(competitor *Competitor, start, at time.Time) LogEntry {
+	competitor.ActualStartTime = start
+	competitor.Status = StatusStarted
+	competitor.CurrentLapNumber = 1
+	competitor.CurrentLapStart = start
+	return logEntry(at, competitor.ID, fmt.Sprintf("The competitor(%d) has started", competitor.ID))
+}
+
+// rank records competitor passing point at the given time and returns their
+// running rank there: one more than the number of competitors who passed
+// it before them and rank ahead.
+func (e *RaceEngine) rank(competitor *Competitor, point timingPoint, at time.Time) int {
+	p := passing{competitor, e.config.raceTime(competitor, at)}
+	rank := 1
+	for _, other := range e.passings[point] {
+		if e.ahead(point, other, p) {
+			rank++
+		}
+	}
+	e.passings[point] = append(e.passings[point], p)
+	return rank
+}
+
+// ahead reports whether a ranks ahead of b at point: with a better race
+// time, or at the finish of a format ranked by crossing order with an equal
+// time and an earlier FinishOrder, as SortStandings orders them.
+func (e *RaceEngine) ahead(point timingPoint, a, b passing) bool {
+	if a.raceTime != b.raceTime {
+		return a.raceTime < b.raceTime
+	}
+	crossingOrder := e.config.Format == FormatPursuit || e.config.Format == FormatMassStart
+	return crossingOrder && point.onLap && point.number == e.config.Laps &&
+		a.competitor.FinishOrder < b.competitor.FinishOrder
+}
+
+// rankPassings replaces the running rank everyone got on passing each
+// timing point with their rank there among all who passed it, so that a
+// later starter who was faster moves ahead of those who passed earlier.
+func (e *RaceEngine) rankPassings() {
+	for point, passings := range e.passings {
+		for _, p := range passings {
+			rank := 1
+			for _, other := range passings {
+				if e.ahead(point, other, p) {
+					rank++
+				}
+			}
+			if !point.onLap {
+				p.competitor.FiringRangeVisits[point.number-1].Rank = rank
+				continue
+			}
+			for i := range p.competitor.LapsCompleted {
+				if p.competitor.LapsCompleted[i].Number == point.number {
+					p.competitor.LapsCompleted[i].Rank = rank
+				}
+			}
+		}
+	}
+}
+
+// reject records a violation for event and returns it as an error for
+// callers that report the event as not applicable at all.
+func (e *RaceEngine) reject(event *Event, competitor *Competitor, code ViolationCode, reason string) error {
+	var status CompetitorStatus
+	if competitor != nil {
+		status = competitor.Status
+	}
+	v := newViolation(event, status, code, reason)
+	e.violations = append(e.violations, v)
+	return &v
+}
+
+// flag records a violation for an event that is still applied.
+func (e *RaceEngine) flag(event *Event, competitor *Competitor, code ViolationCode, reason string) {
+	e.reject(event, competitor, code, reason)
+}
+
+// verifyPenaltyLap flags a penalty lap completed faster than
+// Config.MaxPenaltySpeed allows, treating the loops that could not have
+// been skied in that time as missed.
+func (e *RaceEngine) verifyPenaltyLap(event *Event, competitor *Competitor, penalty PenaltyLap) {
+	if e.config.MaxPenaltySpeed <= 0 || e.config.PenaltyLen <= 0 || penalty.AverageSpeed() <= e.config.MaxPenaltySpeed {
+		return
+	}
+	served := int(penalty.Duration().Seconds() * e.config.MaxPenaltySpeed / e.config.PenaltyLen)
+	missed := penalty.Misses - served
+	if missed <= 0 {
+		return
+	}
+	reason := fmt.Sprintf("penalty laps at %.3f m/s exceed %.3f m/s: %d of %d loops were not served",
+		penalty.AverageSpeed(), e.config.MaxPenaltySpeed, missed, penalty.Misses)
+	e.missedPenalty(event, competitor, missed, CodeShortPenalty, reason)
+}
+
+// missedPenalty flags loops penalty loops that competitor did not serve
+// and, if Config.MissedPenaltyTime is set, adds that much time per loop to
+// the competitor's total time.
+func (e *RaceEngine) missedPenalty(event *Event, competitor *Competitor, loops int, code ViolationCode, reason string) {
+	if e.config.parsedMissedPenaltyTime > 0 {
+		penalty := time.Duration(loops) * e.config.parsedMissedPenaltyTime
+		competitor.TimePenalty += penalty
+		reason += fmt.Sprintf(", %s time penalty applied", FormatDuration(penalty))
+	}
+	e.flag(event, competitor, code, reason)
+}
+
+// checkFiringLine validates the firing line number of an event 5 against
+// Config.FiringLines and against the lines already used on this lap, and
+// returns the number, or 0 if it is missing or not a number.
+func (e *RaceEngine) checkFiringLine(event *Event, competitor *Competitor) int {
+	if len(event.ExtraParams) < 1 {
+		e.flag(event, competitor, CodeMissingParam, "missing firing line number")
+		return 0
+	}
+	line, err := strconv.Atoi(event.ExtraParams[0])
+	if err != nil || line < 1 || line > e.config.FiringLines {
+		e.flag(event, competitor, CodeBadFiringLine, fmt.Sprintf("firing line '%s' is not in 1..%d", event.ExtraParams[0], e.config.FiringLines))
+		return 0
+	}
+	for _, visit := range competitor.FiringRangeVisits {
+		if visit.Lap == competitor.CurrentLapNumber && visit.FiringLine == line {
+			e.flag(event, competitor, CodeRepeatedRange, fmt.Sprintf("firing line %d was already used on lap %d", line, visit.Lap))
+			break
+		}
+	}
+	return line
+}
+
+// roundsFired returns the rounds fired on visit: the count given as event
+// 7's optional parameter, or otherwise one per target plus, if targets were
+// left standing, every spare round, since spares must be used on them
+// before going to the penalty loop. An invalid count is flagged and the
+// default used instead.
+func (e *RaceEngine) roundsFired(event *Event, competitor *Competitor, visit *FiringRangeVisit) int {
+	rounds := visit.Shots
+	if visit.Hits < visit.Shots {
+		rounds += visit.SpareRounds
+	}
+	if len(event.ExtraParams) < 1 {
+		return rounds
+	}
+	most := visit.Shots + visit.SpareRounds
+	fired, err := strconv.Atoi(event.ExtraParams[0])
+	if err != nil || fired < visit.Hits || fired > most {
+		e.flag(event, competitor, CodeBadRounds, fmt.Sprintf("rounds fired '%s' is not in %d..%d", event.ExtraParams[0], visit.Hits, most))
+		return rounds
+	}
+	return fired
+}
+
+// checkRangeVisits flags a lap that ends with more or fewer firing range
+// visits than Config.RangesPerLap.
+func (e *RaceEngine) checkRangeVisits(event *Event, competitor *Competitor) {
+	visits := 0
+	for _, visit := range competitor.FiringRangeVisits {
+		if visit.Lap == competitor.CurrentLapNumber {
+			visits++
+		}
+	}
+	switch {
+	case visits < e.config.RangesPerLap:
+		e.flag(event, competitor, CodeSkippedRange, fmt.Sprintf("%d of %d firing range visits on lap %d", visits, e.config.RangesPerLap, competitor.CurrentLapNumber))
+	case visits > e.config.RangesPerLap:
+		e.flag(event, competitor, CodeRepeatedRange, fmt.Sprintf("%d of %d firing range visits on lap %d", visits, e.config.RangesPerLap, competitor.CurrentLapNumber))
+	}
+}
+
+// Violations returns every violation recorded so far, in the order the
+// offending events were applied.
+func (e *RaceEngine) Violations() []Violation {
+	return append([]Violation(nil), e.violations...)
+}
+
+// disqualifyLateStarters marks every competitor whose start window closed
+// before now as NotStarted.
+func (e *RaceEngine) disqualifyLateStarters(now time.Time) []LogEntry {
+	var out []LogEntry
+	if e.config.sharedStart() {
+		// Competitors who miss the gun may still start late.
+		return out
+	}
+	for _, comp := range e.order {
+		if comp.Status != StatusScheduled && comp.Status != StatusOnStartLine {
+			continue
+		}
+		if comp.ScheduledStartTime.IsZero() || !comp.ActualStartTime.IsZero() {
+			continue
+		}
+		allowedStartWindowEnd := comp.ScheduledStartTime.Add(e.config.parsedStartDelta)
+		if now.After(allowedStartWindowEnd) {
+			comp.Status = StatusNotStarted
+			comp.FinishTime = now
+			msg := fmt.Sprintf("The competitor(%d) is disqualified (Did not start)", comp.ID)
+			out = append(out, outgoingEntry(now, EventDisqualified, comp.ID, msg))
+		}
+	}
+	return out
+}
+
+// Finish closes the race at the time of the last processed event: scheduled
+// competitors who never started become NotStarted and those still on the
+// course become NotFinished. Ranks at every timing point become final. It
+// returns the log entries this produced.
+func (e *RaceEngine) Finish() []LogEntry {
+	var out []LogEntry
+	for _, comp := range e.order {
+		switch comp.Status {
+		case StatusScheduled, StatusOnStartLine:
+			if !comp.ScheduledStartTime.IsZero() && comp.ActualStartTime.IsZero() {
+				comp.Status = StatusNotStarted
+				comp.FinishTime = e.lastProcessedTime
+				msg := fmt.Sprintf("The competitor(%d) is disqualified (Did not start by end of log)", comp.ID)
+				out = append(out, outgoingEntry(e.lastProcessedTime, EventDisqualified, comp.ID, msg))
+			}
+		case StatusStarted, StatusOnLap, StatusOnRange, StatusInPenalty:
+			comp.Status = StatusNotFinished
+			comp.FinishTime = e.lastProcessedTime
+			comp.Comment = "Did not finish before end of log"
+			msg := fmt.Sprintf("The competitor(%d) marked as NotFinished at end of log", comp.ID)
+			out = append(out, logEntry(e.lastProcessedTime, comp.ID, msg))
+		}
+	}
+	e.rankPassings()
+	return e.withStatus(out)
+}
+
+// Competitor returns a deep copy of the state of the competitor with the
+// given ID, or false if they never registered.
+func (e *RaceEngine) Competitor(id int) (Competitor, bool) {
+	c, ok := e.competitors[id]
+	if !ok {
+		return Competitor{}, false
+	}
+	return c.clone(), true
+}
+
+// Snapshot returns a deep copy of every competitor's state ordered by ID.
+func (e *RaceEngine) Snapshot() []Competitor {
+	snapshot := make([]Competitor, 0, len(e.competitors))
+	for _, c := range e.competitors {
+		snapshot = append(snapshot, c.clone())
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	return snapshot
+}
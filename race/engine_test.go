@@ -0,0 +1,908 @@
+package race
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	config, err := LoadConfig(createTempConfigFile(t, `{
+		"laps": 2,
+		"lapLen": 3651,
+		"penaltyLen": 50,
+		"firingLines": 1,
+		"start": "09:30:00",
+		"startDelta": "00:00:30"
+	}`))
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	return config
+}
+
+func applyLines(t *testing.T, engine *RaceEngine, lines ...string) []string {
+	t.Helper()
+	var out []string
+	for _, line := range lines {
+		event, err := ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		entries, err := engine.Apply(event)
+		if err != nil {
+			t.Fatalf("Apply(%q) unexpected error = %v", line, err)
+		}
+		out = append(out, entryLines(entries)...)
+	}
+	return out
+}
+
+// applyNumberedLines applies lines numbered from 1, as in an events file,
+// and returns the violations they caused.
+func applyNumberedLines(t *testing.T, engine *RaceEngine, lines ...string) []Violation {
+	t.Helper()
+	before := len(engine.Violations())
+	for i, line := range lines {
+		event, err := ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		event.Line = i + 1
+		engine.Apply(event)
+	}
+	return engine.Violations()[before:]
+}
+
+// testViolation is the part of a Violation the tests compare.
+type testViolation struct {
+	line   int
+	status CompetitorStatus
+	code   ViolationCode
+	reason string
+}
+
+func testViolations(violations []Violation) []testViolation {
+	var got []testViolation
+	for _, v := range violations {
+		got = append(got, testViolation{v.Line, v.Status, v.Code, v.Reason})
+	}
+	return got
+}
+
+func parseTestTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(timeLayout, value)
+	if err != nil {
+		t.Fatalf("time.Parse(%q) unexpected error = %v", value, err)
+	}
+	return parsed
+}
+
+func entryLines(entries []LogEntry) []string {
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+	return lines
+}
+
+func TestRaceEngine_SpecExample(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+
+	got := applyLines(t, engine,
+		"[09:05:59.867] 1 1",
+		"[09:15:00.841] 2 1 09:30:00.000",
+		"[09:29:45.734] 3 1",
+		"[09:30:01.005] 4 1",
+		"[09:49:31.659] 5 1 1",
+		"[09:49:33.123] 6 1 1",
+		"[09:49:34.650] 6 1 2",
+		"[09:49:35.937] 6 1 4",
+		"[09:49:37.364] 6 1 5",
+		"[09:49:38.339] 7 1",
+		"[09:49:55.915] 8 1",
+		"[09:51:48.391] 9 1",
+		"[09:59:03.872] 10 1",
+		"[09:59:05.321] 11 1 Lost in the forest",
+	)
+	got = append(got, entryLines(engine.Finish())...)
+
+	want := []string{
+		"[09:05:59.867] The competitor(1) registered",
+		"[09:15:00.841] The start time for the competitor(1) was set by a draw to 09:30:00.000",
+		"[09:29:45.734] The competitor(1) is on the start line",
+		"[09:30:01.005] The competitor(1) has started",
+		"[09:49:31.659] The competitor(1) is on the firing range(1)",
+		"[09:49:33.123] The target(1) has been hit by competitor(1)",
+		"[09:49:34.650] The target(2) has been hit by competitor(1)",
+		"[09:49:35.937] The target(4) has been hit by competitor(1)",
+		"[09:49:37.364] The target(5) has been hit by competitor(1)",
+		"[09:49:38.339] The competitor(1) left the firing range",
+		"[09:49:55.915] The competitor(1) entered the penalty laps",
+		"[09:51:48.391] The competitor(1) left the penalty laps",
+		"[09:59:03.872] The competitor(1) ended the main lap",
+		"[09:59:05.321] The competitor(1) can`t continue: Lost in the forest",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output log mismatch.\nGot:\n%s\nWant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	snapshot := engine.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("Snapshot() returned %d competitors, want 1", len(snapshot))
+	}
+	wantLine := "[NotFinished] 1 NotFinished (Lost in the forest) {00:29:02.867, 2.095} {,} {00:01:52.476, 0.445} 4/5 [XX-XX]"
+	if gotLine := ResultLine(engine.config, &snapshot[0]); gotLine != wantLine {
+		t.Errorf("ResultLine() = %q, want %q", gotLine, wantLine)
+	}
+}
+
+func TestRaceEngine_StartWindow(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+
+	got := applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 1 2",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:10:01.000] 2 2 09:31:00.000",
+	)
+	event, _ := ParseEvent("[09:30:31.000] 4 1")
+	entries, err := engine.Apply(event)
+	var v *Violation
+	if !errors.As(err, &v) || v.Code != CodeAlreadyFinished {
+		t.Fatalf("Apply(%q) error = %v, want a %s violation", event.RawLine, err, CodeAlreadyFinished)
+	}
+	got = append(got, entryLines(entries)...)
+	got = append(got, applyLines(t, engine, "[09:31:40.000] 1 3")...)
+
+	want := []string{
+		"[09:30:31.000] The competitor(1) is disqualified (Did not start)",
+		"[09:31:40.000] The competitor(2) is disqualified (Did not start)",
+		"[09:31:40.000] The competitor(3) registered",
+	}
+	if tail := got[len(got)-len(want):]; strings.Join(tail, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output log tail mismatch.\nGot:\n%s\nWant:\n%s", strings.Join(tail, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, c := range engine.Snapshot() {
+		if c.ID != 3 && c.Status != StatusNotStarted {
+			t.Errorf("competitor %d status = %s, want %s", c.ID, c.Status, StatusNotStarted)
+		}
+	}
+}
+
+func TestRaceEngine_UnknownCompetitor(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	event, _ := ParseEvent("[09:00:00.000] 4 7")
+
+	lines, err := engine.Apply(event)
+	var v *Violation
+	if !errors.As(err, &v) || v.Reason != "unknown competitor" {
+		t.Errorf("Apply() error = %v, want unknown competitor violation", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("Apply() returned log lines %v, want none", lines)
+	}
+}
+
+func TestRaceEngine_Finish(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 2 1 09:30:00.000",
+		"[09:30:00.500] 4 1",
+		"[09:40:00.000] 5 1 1",
+	)
+
+	got := entryLines(engine.Finish())
+	want := "[09:40:00.000] The competitor(1) marked as NotFinished at end of log"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("Finish() = %v, want [%q]", got, want)
+	}
+
+	c := engine.Snapshot()[0]
+	if c.Status != StatusNotFinished {
+		t.Errorf("status after Finish() = %s, want %s", c.Status, StatusNotFinished)
+	}
+	if c.CurrentRangeVisit == nil || c.CurrentRangeVisit == engine.competitors[1].CurrentRangeVisit {
+		t.Errorf("Snapshot() must deep-copy CurrentRangeVisit")
+	}
+	if got, want := c.FinishTime.Sub(c.ScheduledStartTime), 10*time.Minute; got != want {
+		t.Errorf("FinishTime - ScheduledStartTime = %v, want %v", got, want)
+	}
+}
+
+func TestRaceEngine_OutgoingEvents(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 1
+	engine := NewRaceEngine(config)
+
+	var outgoing []string
+	for _, line := range []string{
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 1 2",
+		"[09:00:02.000] 1 3",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:10:01.000] 2 2 09:31:00.000",
+		"[09:10:02.000] 2 3 09:50:00.000",
+		"[09:30:10.000] 4 1",
+		"[09:45:00.000] 10 1",
+		"[09:45:00.000] 33 1",
+	} {
+		event, _ := ParseEvent(line)
+		entries, err := engine.Apply(event)
+		if err != nil {
+			t.Fatalf("Apply(%q) unexpected error = %v", line, err)
+		}
+		for _, entry := range entries {
+			if entry.Event != nil {
+				outgoing = append(outgoing, entry.Event.String())
+			}
+		}
+	}
+	for _, entry := range engine.Finish() {
+		if entry.Event != nil {
+			outgoing = append(outgoing, entry.Event.String())
+		}
+	}
+
+	want := []string{
+		"[09:45:00.000] 32 2",
+		"[09:45:00.000] 33 1",
+		"[09:45:00.000] 32 3",
+	}
+	if strings.Join(outgoing, "\n") != strings.Join(want, "\n") {
+		t.Errorf("outgoing events mismatch.\nGot:\n%s\nWant:\n%s", strings.Join(outgoing, "\n"), strings.Join(want, "\n"))
+	}
+	for _, line := range outgoing {
+		event, err := ParseEvent(line)
+		if err != nil {
+			t.Fatalf("ParseEvent(%q) unexpected error = %v", line, err)
+		}
+		if !event.IsOutgoing() {
+			t.Errorf("ParseEvent(%q).IsOutgoing() = false, want true", line)
+		}
+	}
+}
+
+func TestRaceEngine_Violations(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	lines := []string{
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 3 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:29:00.000] 9 1",
+		"[09:40:00.000] 6 1 1",
+		"[09:41:00.000] 42 1",
+	}
+	got := testViolations(applyNumberedLines(t, engine, lines...))
+
+	want := []testViolation{
+		{2, StatusRegistered, CodeWrongState, "competitor has no start time drawn"},
+		{5, StatusStarted, CodeBadTime, "event time is before the previous event at [09:30:01.000]"},
+		{5, StatusStarted, CodeWrongState, "competitor is not in the penalty laps"},
+		{6, StatusStarted, CodeWrongState, "competitor is not on the firing range"},
+		{7, StatusStarted, CodeUnknownEvent, "unknown event ID"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Violations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRaceEngine_StartTimeSetAfterStart(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+	)
+
+	event, _ := ParseEvent("[09:35:00.000] 2 1 09:40:00.000")
+	var v *Violation
+	if _, err := engine.Apply(event); !errors.As(err, &v) || v.Code != CodeWrongState {
+		t.Fatalf("Apply(%q) error = %v, want a %s violation", event.RawLine, err, CodeWrongState)
+	}
+	applyLines(t, engine, "[09:40:00.000] 5 1 1")
+	c := engine.Snapshot()[0]
+	if got := c.ScheduledStartTime.Format(timeLayout); c.Status != StatusOnRange || got != "09:30:00.000" {
+		t.Errorf("status = %s, scheduled start = %s, want %s and 09:30:00.000", c.Status, got, StatusOnRange)
+	}
+}
+
+func TestRaceEngine_TargetHits(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:01.000] 6 1 4",
+		"[09:40:02.000] 6 1 2",
+	)
+
+	tests := []struct {
+		name string
+		line string
+		code ViolationCode
+	}{
+		{"Duplicate", "[09:40:03.000] 6 1 4", CodeDuplicateHit},
+		{"Out Of Range", "[09:40:04.000] 6 1 9", CodeBadTarget},
+		{"Zero", "[09:40:05.000] 6 1 0", CodeBadTarget},
+		{"Not A Number", "[09:40:06.000] 6 1 x", CodeBadTarget},
+		{"Missing", "[09:40:07.000] 6 1", CodeMissingParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, _ := ParseEvent(tt.line)
+			entries, err := engine.Apply(event)
+			var v *Violation
+			if !errors.As(err, &v) || v.Code != tt.code {
+				t.Errorf("Apply(%q) error = %v, want %s violation", tt.line, err, tt.code)
+			}
+			if len(entries) != 0 {
+				t.Errorf("Apply(%q) logged %v, want nothing", tt.line, entryLines(entries))
+			}
+		})
+	}
+
+	applyLines(t, engine, "[09:40:10.000] 7 1")
+	c := engine.Snapshot()[0]
+	visit := c.FiringRangeVisits[0]
+	if visit.Hits != 2 || c.TotalHits != 2 || c.LastMisses != 3 {
+		t.Errorf("hits = %d, total hits = %d, misses = %d, want 2, 2, 3", visit.Hits, c.TotalHits, c.LastMisses)
+	}
+	if !reflect.DeepEqual(visit.HitTargets, []int{4, 2}) {
+		t.Errorf("HitTargets = %v, want [4 2]", visit.HitTargets)
+	}
+	if got := visit.HitPattern(); got != "-X-X-" {
+		t.Errorf("HitPattern() = %q, want %q", got, "-X-X-")
+	}
+}
+
+func TestRaceEngine_FiringLines(t *testing.T) {
+	config := newTestConfig(t)
+	config.FiringLines = 2
+	config.RangesPerLap = 2
+	engine := NewRaceEngine(config)
+	// Every visit is clean so that no penalty laps are owed.
+	cleanVisit := func(at, firingLine string) []string {
+		return []string{
+			"[" + at + ".000] 5 1 " + firingLine,
+			"[" + at + ".001] 6 1 1",
+			"[" + at + ".002] 6 1 2",
+			"[" + at + ".003] 6 1 3",
+			"[" + at + ".004] 6 1 4",
+			"[" + at + ".005] 6 1 5",
+			"[" + at + ".006] 7 1",
+		}
+	}
+	lines := []string{
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+	}
+	lines = append(lines, cleanVisit("09:40:00", "1")...)
+	lines = append(lines, cleanVisit("09:41:00", "1")...)
+	lines = append(lines, "[09:50:00.000] 10 1")
+	lines = append(lines, cleanVisit("09:55:00", "3")...)
+	lines = append(lines, "[10:10:00.000] 10 1")
+	got := testViolations(applyNumberedLines(t, engine, lines...))
+
+	want := []testViolation{
+		{11, StatusOnRange, CodeRepeatedRange, "firing line 1 was already used on lap 1"},
+		{19, StatusOnRange, CodeBadFiringLine, "firing line '3' is not in 1..2"},
+		{26, StatusOnLap, CodeSkippedRange, "1 of 2 firing range visits on lap 2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Violations() = %+v, want %+v", got, want)
+	}
+
+	c := engine.Snapshot()[0]
+	if c.Status != StatusFinished {
+		t.Errorf("status = %s, want %s: firing line violations must not stop the race", c.Status, StatusFinished)
+	}
+	var visits [][2]int
+	for _, v := range c.FiringRangeVisits {
+		visits = append(visits, [2]int{v.Lap, v.FiringLine})
+	}
+	if want := [][2]int{{1, 1}, {1, 1}, {2, 0}}; !reflect.DeepEqual(visits, want) {
+		t.Errorf("visits (lap, firing line) = %v, want %v", visits, want)
+	}
+}
+
+func TestRaceEngine_PenaltyVerification(t *testing.T) {
+	config := newTestConfig(t)
+	config.MaxPenaltySpeed = 5
+	config.parsedMissedPenaltyTime = 10 * time.Second
+	engine := NewRaceEngine(config)
+	lines := []string{
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:01.000] 6 1 1",
+		"[09:40:02.000] 6 1 2",
+		"[09:40:10.000] 7 1",
+		"[09:41:00.000] 8 1",
+		"[09:41:10.000] 9 1",
+		"[09:50:00.000] 10 1",
+		"[09:55:00.000] 5 1 1",
+		"[09:55:10.000] 7 1",
+		"[10:05:00.000] 10 1",
+	}
+	got := testViolations(applyNumberedLines(t, engine, lines...))
+
+	want := []testViolation{
+		{9, StatusOnLap, CodeShortPenalty, "penalty laps at 15.000 m/s exceed 5.000 m/s: 2 of 3 loops were not served, 00:00:20.000 time penalty applied"},
+		{13, StatusOnLap, CodeMissingPenalty, "5 penalty laps were not served, 00:00:50.000 time penalty applied"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Violations() = %+v, want %+v", got, want)
+	}
+
+	c := engine.Snapshot()[0]
+	if c.Status != StatusFinished {
+		t.Errorf("status = %s, want %s", c.Status, StatusFinished)
+	}
+	if c.TimePenalty != 70*time.Second {
+		t.Errorf("TimePenalty = %v, want 1m10s", c.TimePenalty)
+	}
+	if want := 36*time.Minute + 10*time.Second; c.TotalTime() != want {
+		t.Errorf("TotalTime() = %v, want %v", c.TotalTime(), want)
+	}
+	if c.PenaltyLapsCompleted[0].Misses != 3 {
+		t.Errorf("penalty lap misses = %d, want 3", c.PenaltyLapsCompleted[0].Misses)
+	}
+}
+
+func TestRaceEngine_MissingPenaltyWithoutTimePenalty(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:10.000] 7 1",
+	)
+	event, _ := ParseEvent("[09:50:00.000] 10 1")
+	var v *Violation
+	if _, err := engine.Apply(event); !errors.As(err, &v) || v.Code != CodeMissingPenalty {
+		t.Fatalf("Apply(%q) error = %v, want a %s violation", event.RawLine, err, CodeMissingPenalty)
+	}
+
+	violations := engine.Violations()
+	if len(violations) != 1 || violations[0].Code != CodeMissingPenalty {
+		t.Fatalf("Violations() = %v, want one %s violation", violations, CodeMissingPenalty)
+	}
+	c := engine.Snapshot()[0]
+	if len(c.LapsCompleted) != 0 || c.TimePenalty != 0 {
+		t.Errorf("laps = %d, time penalty = %v, want the lap ignored and no time penalty", len(c.LapsCompleted), c.TimePenalty)
+	}
+}
+
+func TestRaceEngine_IndividualFormat(t *testing.T) {
+	config := newTestConfig(t)
+	config.Format = FormatIndividual
+	config.parsedPenaltyTime = time.Minute
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:01.000] 6 1 1",
+		"[09:40:02.000] 6 1 2",
+		"[09:40:03.000] 6 1 3",
+		"[09:40:10.000] 7 1",
+		"[09:50:00.000] 10 1",
+	)
+
+	event, _ := ParseEvent("[09:50:01.000] 8 1")
+	var v *Violation
+	if _, err := engine.Apply(event); !errors.As(err, &v) || v.Code != CodeWrongState {
+		t.Fatalf("Apply(%q) error = %v, want a %s violation", event.RawLine, err, CodeWrongState)
+	}
+	if v := engine.Violations(); len(v) != 1 || v[0].Code != CodeWrongState {
+		t.Errorf("Violations() = %v, want penalty laps rejected in the individual format", v)
+	}
+
+	applyLines(t, engine,
+		"[09:55:00.000] 5 1 1",
+		"[09:55:10.000] 7 1",
+		"[10:05:00.000] 10 1",
+	)
+	c := engine.Snapshot()[0]
+	if want := 7 * time.Minute; c.TimePenalty != want {
+		t.Errorf("TimePenalty = %v, want %v", c.TimePenalty, want)
+	}
+	if want := 42 * time.Minute; c.TotalTime() != want {
+		t.Errorf("TotalTime() = %v, want %v", c.TotalTime(), want)
+	}
+	want := "[Finished] 1 00:42:00.000 {00:19:59.000, 3.045} {00:15:00.000, 4.057} {+00:07:00.000} 3/10 [XXX-- -----]"
+	if got := ResultLine(config, &c); got != want {
+		t.Errorf("ResultLine() = %q, want %q", got, want)
+	}
+}
+
+func TestRaceEngine_MassStart(t *testing.T) {
+	config := newTestConfig(t)
+	config.Format = FormatMassStart
+	config.Laps = 1
+	config.RangesPerLap = 0
+	engine := NewRaceEngine(config)
+	got := applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 1 2",
+		"[09:00:02.000] 1 3",
+		"[09:00:03.000] 1 4",
+		"[09:30:00.000] 4 1",
+		"[09:50:00.000] 10 2",
+		"[09:50:00.000] 10 1",
+		"[09:50:00.000] 10 3",
+	)
+	want := []string{
+		"[09:00:00.000] The competitor(1) registered",
+		"[09:00:00.000] The start time for the competitor(1) was set by mass start to 09:30:00.000",
+		"[09:00:01.000] The competitor(2) registered",
+		"[09:00:01.000] The start time for the competitor(2) was set by mass start to 09:30:00.000",
+		"[09:00:02.000] The competitor(3) registered",
+		"[09:00:02.000] The start time for the competitor(3) was set by mass start to 09:30:00.000",
+		"[09:00:03.000] The competitor(4) registered",
+		"[09:00:03.000] The start time for the competitor(4) was set by mass start to 09:30:00.000",
+		"[09:30:00.000] The competitor(1) has started",
+		// Competitors without event 4 are logged as started when their
+		// first event on the course arrives.
+		"[09:50:00.000] The competitor(2) has started",
+		"[09:50:00.000] The competitor(2) ended the main lap",
+		"[09:50:00.000] The competitor(2) has finished",
+		"[09:50:00.000] The competitor(1) ended the main lap",
+		"[09:50:00.000] The competitor(1) has finished",
+		"[09:50:00.000] The competitor(3) has started",
+		"[09:50:00.000] The competitor(3) ended the main lap",
+		"[09:50:00.000] The competitor(3) has finished",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+	if v := engine.Violations(); len(v) != 0 {
+		t.Errorf("Violations() = %v, want none", v)
+	}
+	// They still went off with the gun.
+	for _, c := range engine.Snapshot()[:3] {
+		if got := c.ActualStartTime.Format(timeLayout); got != "09:30:00.000" {
+			t.Errorf("competitor %d ActualStartTime = %s, want the gun at 09:30:00.000", c.ID, got)
+		}
+	}
+
+	event, _ := ParseEvent("[09:50:01.000] 2 4 09:31:00.000")
+	engine.Apply(event)
+	if v := engine.Violations(); len(v) != 1 || v[0].Code != CodeWrongState {
+		t.Errorf("Violations() = %v, want the draw rejected", v)
+	}
+
+	engine.Finish()
+	standings := engine.Snapshot()
+	SortStandings(config, standings)
+	var ids []int
+	for _, c := range standings {
+		ids = append(ids, c.ID)
+	}
+	if want := []int{2, 1, 3, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("standings = %v, want %v by crossing order", ids, want)
+	}
+	// The photo finish splits the finish ranks the same way.
+	for i, c := range standings[:3] {
+		if rank := c.LapsCompleted[0].Rank; rank != i+1 {
+			t.Errorf("competitor %d finish rank = %d, want %d", c.ID, rank, i+1)
+		}
+	}
+	if standings[3].Status != StatusNotStarted {
+		t.Errorf("competitor 4 status = %s, want %s", standings[3].Status, StatusNotStarted)
+	}
+}
+
+func TestRaceEngine_ShootingPositions(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 3
+	config.ShootingSequence = []ShootingPosition{PositionProne, PositionStanding}
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:01.000] 6 1 1",
+		"[09:40:02.000] 6 1 2",
+		"[09:40:03.000] 6 1 3",
+		"[09:40:04.000] 6 1 4",
+		"[09:40:05.000] 6 1 5",
+		"[09:40:10.000] 7 1",
+		"[09:50:00.000] 10 1",
+		"[09:55:00.000] 5 1 1",
+		"[09:55:01.000] 6 1 3",
+		"[09:55:10.000] 7 1",
+		"[09:56:00.000] 8 1",
+		"[09:59:00.000] 9 1",
+		"[10:05:00.000] 10 1",
+		"[10:10:00.000] 5 1 1",
+		"[10:10:01.000] 6 1 2",
+		"[10:10:02.000] 6 1 4",
+		"[10:10:03.000] 6 1 5",
+		"[10:10:10.000] 7 1",
+	)
+
+	c := engine.Snapshot()[0]
+	var positions []ShootingPosition
+	for _, visit := range c.FiringRangeVisits {
+		positions = append(positions, visit.Position)
+	}
+	if want := []ShootingPosition{PositionProne, PositionStanding, PositionProne}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("visit positions = %v, want %v", positions, want)
+	}
+	want := []PositionTotal{
+		{Position: PositionProne, Hits: 8, Shots: 10},
+		{Position: PositionStanding, Hits: 1, Shots: 5},
+	}
+	if got := c.PositionTotals(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PositionTotals() = %+v, want %+v", got, want)
+	}
+	if line := ResultLine(config, &c); !strings.HasSuffix(line, "9/15 [XXXXX --X-- -X-XX] (prone 8/10, standing 1/5)") {
+		t.Errorf("ResultLine() = %q, want the shooting broken down by position", line)
+	}
+}
+
+func TestRaceEngine_LapLengths(t *testing.T) {
+	config := newTestConfig(t)
+	config.RangesPerLap = 0
+	config.LapLens = []float64{3300, 2500}
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:41:00.000] 10 1",
+		"[09:51:00.000] 10 1",
+	)
+
+	c := engine.Snapshot()[0]
+	var distances []float64
+	for _, lap := range c.LapsCompleted {
+		distances = append(distances, lap.Distance)
+	}
+	if want := []float64{3300, 2500}; !reflect.DeepEqual(distances, want) {
+		t.Errorf("lap distances = %v, want %v", distances, want)
+	}
+}
+
+func TestRaceEngine_ShotsPerVisit(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 1
+	config.Shots = 3
+	config.SpareRounds = 2
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:01.000] 6 1 3",
+	)
+	event, _ := ParseEvent("[09:40:02.000] 6 1 4")
+	var v *Violation
+	if _, err := engine.Apply(event); !errors.As(err, &v) || v.Code != CodeBadTarget {
+		t.Errorf("Apply(%q) error = %v, want %s violation", event.RawLine, err, CodeBadTarget)
+	}
+	applyLines(t, engine,
+		"[09:40:10.000] 7 1",
+		"[09:41:00.000] 8 1",
+		"[09:42:00.000] 9 1",
+		"[09:50:00.000] 10 1",
+	)
+
+	c := engine.Snapshot()[0]
+	visit := c.FiringRangeVisits[0]
+	if visit.Shots != 3 || visit.SpareRounds != 2 {
+		t.Errorf("visit shots = %d, spare rounds = %d, want 3, 2", visit.Shots, visit.SpareRounds)
+	}
+	if got := c.PenaltyLapsCompleted[0].Distance; got != 100 {
+		t.Errorf("penalty distance = %v, want 100 for 2 misses", got)
+	}
+	if line := ResultLine(config, &c); !strings.HasSuffix(line, " 1/5 [--X]") {
+		t.Errorf("ResultLine() = %q, want 1/5 shooting with both spare rounds fired", line)
+	}
+}
+
+func TestRaceEngine_RoundsFired(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 1
+	config.Shots = 3
+	config.SpareRounds = 2
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:00.000] 1 2",
+		"[09:00:00.000] 1 3",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:10:00.000] 2 2 09:30:00.000",
+		"[09:10:00.000] 2 3 09:30:00.000",
+		"[09:30:01.000] 4 1",
+		"[09:30:01.000] 4 2",
+		"[09:30:01.000] 4 3",
+		"[09:40:00.000] 5 1 1",
+		"[09:40:00.000] 5 2 1",
+		"[09:40:00.000] 5 3 1",
+	)
+	for _, target := range []string{"1", "2", "3"} {
+		applyLines(t, engine,
+			"[09:40:01.000] 6 1 "+target,
+			"[09:40:01.000] 6 2 "+target,
+		)
+	}
+	applyLines(t, engine,
+		"[09:40:01.000] 6 3 1",
+		"[09:40:10.000] 7 1",
+		"[09:40:10.000] 7 2 4",
+		"[09:40:10.000] 7 3 6",
+	)
+
+	want := map[int]int{1: 3, 2: 4, 3: 5}
+	for _, c := range engine.Snapshot() {
+		if got := c.FiringRangeVisits[0].RoundsFired; got != want[c.ID] {
+			t.Errorf("competitor %d rounds fired = %d, want %d", c.ID, got, want[c.ID])
+		}
+	}
+	violations := engine.Violations()
+	if len(violations) != 1 || violations[0].Code != CodeBadRounds || violations[0].Event.CompetitorID != 3 {
+		t.Errorf("Violations() = %v, want one %s violation for competitor 3", violations, CodeBadRounds)
+	}
+}
+
+func TestRaceEngine_Checkpoints(t *testing.T) {
+	config := newTestConfig(t)
+	config.RangesPerLap = 0
+	config.Checkpoints = []float64{1000, 2000, 3000}
+	engine := NewRaceEngine(config)
+	got := applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:30:00.000] 4 1",
+		"[09:35:00.000] 12 1 1",
+		"[09:45:00.000] 12 1 3",
+		"[09:50:00.000] 10 1",
+		"[09:55:00.000] 12 1 2",
+	)
+	want := []string{
+		"[09:00:00.000] The competitor(1) registered",
+		"[09:10:00.000] The start time for the competitor(1) was set by a draw to 09:30:00.000",
+		"[09:30:00.000] The competitor(1) has started",
+		"[09:35:00.000] The competitor(1) passed the checkpoint(1)",
+		"[09:45:00.000] The competitor(1) passed the checkpoint(3)",
+		"[09:50:00.000] The competitor(1) ended the main lap",
+		"[09:55:00.000] The competitor(1) passed the checkpoint(2)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Checkpoints must be passed in order, and only configured ones exist.
+	for _, tt := range []struct {
+		line string
+		code ViolationCode
+	}{
+		{"[09:56:00.000] 12 1 1", CodeBadCheckpoint},
+		{"[09:56:00.000] 12 1 4", CodeBadCheckpoint},
+		{"[09:56:00.000] 12 1", CodeMissingParam},
+	} {
+		event, _ := ParseEvent(tt.line)
+		engine.Apply(event)
+		violations := engine.Violations()
+		if v := violations[len(violations)-1]; v.Code != tt.code || v.RawLine != tt.line {
+			t.Errorf("Apply(%q) violation = %v, want %s", tt.line, v, tt.code)
+		}
+	}
+
+	c := engine.Snapshot()[0]
+	// Checkpoint 2 was not recorded on lap 1, so its sectors merge.
+	wantSectors := []Sector{
+		{From: 0, To: 1000, StartTime: parseTestTime(t, "09:30:00.000"), EndTime: parseTestTime(t, "09:35:00.000")},
+		{From: 1000, To: 3000, StartTime: parseTestTime(t, "09:35:00.000"), EndTime: parseTestTime(t, "09:45:00.000")},
+		{From: 3000, To: 3651, StartTime: parseTestTime(t, "09:45:00.000"), EndTime: parseTestTime(t, "09:50:00.000")},
+	}
+	if sectors := c.LapsCompleted[0].Sectors(); !reflect.DeepEqual(sectors, wantSectors) {
+		t.Errorf("lap 1 sectors = %+v, want %+v", sectors, wantSectors)
+	}
+	if speed := wantSectors[1].AverageSpeed(); speed != 2000.0/600 {
+		t.Errorf("sector 2 speed = %v, want %v", speed, 2000.0/600)
+	}
+	if len(c.CurrentSplits) != 1 || c.CurrentSplits[0].Checkpoint != 2 || c.CurrentSplits[0].Distance != 2000 {
+		t.Errorf("lap 2 splits = %+v, want checkpoint 2 at 2000 m", c.CurrentSplits)
+	}
+	wantLine := "[OnLap] 1 OnLap {00:20:00.000, 3.042} [{00:05:00.000, 3.333} {00:10:00.000, 3.333} {00:05:00.000, 2.170}] {,} {00:00:00.000, 0.000} 0/0"
+	if gotLine := ResultLine(config, &c); gotLine != wantLine {
+		t.Errorf("ResultLine() = %q, want %q", gotLine, wantLine)
+	}
+}
+
+func TestRaceEngine_RunningRanks(t *testing.T) {
+	config := newTestConfig(t)
+	config.Shots = 1
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:00.000] 1 2",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:10:00.000] 2 2 09:30:30.000",
+		"[09:30:00.000] 4 1",
+		"[09:30:30.000] 4 2",
+		"[09:39:50.000] 5 1 1",
+		"[09:39:55.000] 6 1 1",
+		"[09:40:00.000] 7 1",
+		"[09:40:00.000] 5 2 1",
+		"[09:40:05.000] 6 2 1",
+		// Competitor 2 leaves the range 9:40 after their start, faster
+		// than competitor 1's 10:00.
+		"[09:40:10.000] 7 2",
+		"[09:45:00.000] 10 1",
+		"[09:46:00.000] 10 2",
+		"[09:58:55.000] 5 2 1",
+		"[09:58:56.000] 6 2 1",
+		"[09:58:57.000] 7 2",
+		"[09:59:00.000] 10 2",
+		"[09:59:50.000] 5 1 1",
+		"[09:59:55.000] 6 1 1",
+		"[09:59:58.000] 7 1",
+		"[10:00:00.000] 10 1",
+	)
+
+	// Live ranks only count those who had passed each point by then.
+	want := map[int]string{
+		1: "1: shooting 1 1, lap 1 1, shooting 2 2 (-1), lap 2 2",
+		2: "2: shooting 1 1, lap 1 2 (-1), shooting 2 1 (+1), lap 2 1",
+	}
+	for _, c := range engine.Snapshot() {
+		if got := ProgressionLine(&c); got != want[c.ID] {
+			t.Errorf("running ProgressionLine(%d) = %q, want %q", c.ID, got, want[c.ID])
+		}
+	}
+
+	// At the finish competitor 2, who started later, is ahead on the
+	// first shooting too.
+	engine.Finish()
+	want[1] = "1: shooting 1 2, lap 1 1 (+1), shooting 2 2 (-1), lap 2 2"
+	for _, c := range engine.Snapshot() {
+		if got := ProgressionLine(&c); got != want[c.ID] {
+			t.Errorf("final ProgressionLine(%d) = %q, want %q", c.ID, got, want[c.ID])
+		}
+	}
+}
+
+func TestRaceEngine_FinalRanks(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 1
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:00.000] 1 2",
+		"[09:10:00.000] 2 1 09:30:00.000",
+		"[09:10:00.000] 2 2 09:30:30.000",
+		"[09:30:00.000] 4 1",
+		"[09:30:30.000] 4 2",
+		// Competitor 1 ends the lap first, in 20:00; competitor 2 ten
+		// seconds later, in 19:40.
+		"[09:50:00.000] 10 1",
+		"[09:50:10.000] 10 2",
+	)
+	engine.Finish()
+
+	want := map[int]int{1: 2, 2: 1}
+	for _, c := range engine.Snapshot() {
+		if got := c.LapsCompleted[0].Rank; got != want[c.ID] {
+			t.Errorf("competitor %d lap 1 rank = %d, want %d", c.ID, got, want[c.ID])
+		}
+	}
+}
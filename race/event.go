@@ -0,0 +1,118 @@
+package race
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeLayout = "15:04:05.000" // HH:MM:SS.sss
+const eventTimeLayout = "[" + timeLayout + "]"
+
+// Incoming event IDs as defined by the competition spec.
+const (
+	EventRegistered      = 1
+	EventStartTimeSet    = 2
+	EventOnStartLine     = 3
+	EventStarted         = 4
+	EventOnFiringRange   = 5
+	EventTargetHit       = 6
+	EventLeftFiringRange = 7
+	EventEnteredPenalty  = 8
+	EventLeftPenalty     = 9
+	EventEndedMainLap    = 10
+	EventCannotContinue  = 11
+	// EventCheckpoint reports passing an intermediate checkpoint of the
+	// lap; its extra parameter is the checkpoint number from 1.
+	EventCheckpoint = 12
+)
+
+// Outgoing event IDs generated by the engine.
+const (
+	EventDisqualified = 32
+	EventFinished     = 33
+)
+
+type Event struct {
+	Time         time.Time
+	ID           int
+	CompetitorID int
+	ExtraParams  []string
+	RawLine      string
+	// Line is the event's line number in its source, if known.
+	Line int
+}
+
+// IsOutgoing reports whether e is an event generated by the engine rather
+// than one received from the course.
+func (e *Event) IsOutgoing() bool {
+	return e.ID == EventDisqualified || e.ID == EventFinished
+}
+
+// String formats e in the same "[HH:MM:SS.sss] eventID competitorID
+// extraParams" form that ParseEvent reads.
+func (e *Event) String() string {
+	s := fmt.Sprintf("%s %d %d", e.Time.Format(eventTimeLayout), e.ID, e.CompetitorID)
+	if len(e.ExtraParams) > 0 {
+		s += " " + strings.Join(e.ExtraParams, " ")
+	}
+	return s
+}
+
+// ParseEvent parses a single "[HH:MM:SS.sss] eventID competitorID extraParams"
+// line. Blank lines yield a nil event and no error.
+func ParseEvent(line string) (*Event, error) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("invalid event format: %s", line)
+	}
+
+	timeStr := parts[0]
+	eventIDStr := parts[1]
+	competitorIDStr := ""
+	extraParamsStr := ""
+
+	remainingParts := strings.SplitN(parts[2], " ", 2)
+	competitorIDStr = remainingParts[0]
+	if len(remainingParts) > 1 {
+		extraParamsStr = remainingParts[1]
+	}
+
+	eventTime, err := time.Parse(eventTimeLayout, timeStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time format %s: %w", timeStr, err)
+	}
+
+	eventID, err := strconv.Atoi(eventIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event ID %s: %w", eventIDStr, err)
+	}
+
+	competitorID, err := strconv.Atoi(competitorIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid competitor ID %s: %w", competitorIDStr, err)
+	}
+
+	var extraParams []string
+	if extraParamsStr != "" {
+		if eventID == EventCannotContinue {
+			extraParams = []string{extraParamsStr}
+		} else {
+			extraParams = strings.Fields(extraParamsStr)
+		}
+	}
+
+	return &Event{
+		Time:         eventTime,
+		ID:           eventID,
+		CompetitorID: competitorID,
+		ExtraParams:  extraParams,
+		RawLine:      line,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package race
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantID    int
+		wantComp  int
+		wantExtra []string
+		expectErr bool
+	}{
+		{"No Params", "[09:05:59.867] 1 1", 1, 1, nil, false},
+		{"Start Time", "[09:15:00.841] 2 1 09:30:00.000", 2, 1, []string{"09:30:00.000"}, false},
+		{"Comment Kept Whole", "[09:59:05.321] 11 1 Lost in the forest", 11, 1, []string{"Lost in the forest"}, false},
+		{"Outgoing", "[09:59:05.321] 33 1", 33, 1, nil, false},
+		{"Too Few Parts", "[09:05:59.867] 1", 0, 0, nil, true},
+		{"Bad Time", "[09:05:59] 1 1", 0, 0, nil, true},
+		{"Bad Event ID", "[09:05:59.867] x 1", 0, 0, nil, true},
+		{"Bad Competitor ID", "[09:05:59.867] 1 x", 0, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEvent(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseEvent(%q) error = %v, expectErr %v", tt.input, err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			if got.ID != tt.wantID || got.CompetitorID != tt.wantComp || !reflect.DeepEqual(got.ExtraParams, tt.wantExtra) {
+				t.Errorf("ParseEvent(%q) = %+v, want ID %d competitor %d params %v", tt.input, got, tt.wantID, tt.wantComp, tt.wantExtra)
+			}
+			if s := got.String(); s != tt.input {
+				t.Errorf("Event.String() = %q, want %q", s, tt.input)
+			}
+		})
+	}
+}
+
+func TestParseEvent_Blank(t *testing.T) {
+	got, err := ParseEvent("   ")
+	if got != nil || err != nil {
+		t.Errorf("ParseEvent(blank) = %v, %v, want nil, nil", got, err)
+	}
+}
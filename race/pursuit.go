@@ -0,0 +1,101 @@
+package race
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PreviousResult is one finisher's total time in an earlier race.
+type PreviousResult struct {
+	ID        int
+	TotalTime time.Duration
+}
+
+// LoadPreviousResults reads the finishers from a result table written by an
+// earlier run: the JSON report if path ends in ".json", the text table
+// otherwise. Competitors who did not finish are skipped.
+func LoadPreviousResults(path string) ([]PreviousResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening previous results file: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSONResults(file)
+	}
+	return parseResultTable(file)
+}
+
+// parseResultTable reads the "[Finished] <id> <total time> ..." rows of a
+// text result table.
+func parseResultTable(r io.Reader) ([]PreviousResult, error) {
+	var results []PreviousResult
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "[Finished]" {
+			continue
+		}
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid competitor ID '%s': %w", lineNumber, fields[1], err)
+		}
+		totalTime, err := ParseDuration(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid total time '%s': %w", lineNumber, fields[2], err)
+		}
+		results = append(results, PreviousResult{ID: id, TotalTime: totalTime})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading previous results: %w", err)
+	}
+	return results, nil
+}
+
+func parseJSONResults(r io.Reader) ([]PreviousResult, error) {
+	var reports []CompetitorReport
+	if err := json.NewDecoder(r).Decode(&reports); err != nil {
+		return nil, fmt.Errorf("error parsing previous results JSON: %w", err)
+	}
+	var results []PreviousResult
+	for _, report := range reports {
+		if report.Status != StatusFinished {
+			continue
+		}
+		totalTime, err := ParseDuration(report.TotalTime)
+		if err != nil {
+			return nil, fmt.Errorf("competitor %d: invalid total time '%s': %w", report.ID, report.TotalTime, err)
+		}
+		results = append(results, PreviousResult{ID: report.ID, TotalTime: totalTime})
+	}
+	return results, nil
+}
+
+// pursuitGaps returns each finisher's time behind the winner, which is
+// their start delay in a pursuit race.
+func pursuitGaps(results []PreviousResult) map[int]time.Duration {
+	gaps := make(map[int]time.Duration, len(results))
+	if len(results) == 0 {
+		return gaps
+	}
+	sorted := append([]PreviousResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TotalTime < sorted[j].TotalTime
+	})
+	winner := sorted[0].TotalTime
+	for _, result := range sorted {
+		gaps[result.ID] = result.TotalTime - winner
+	}
+	return gaps
+}
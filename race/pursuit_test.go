@@ -0,0 +1,97 @@
+package race
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadPreviousResults(t *testing.T) {
+	want := []PreviousResult{
+		{ID: 2, TotalTime: 29*time.Minute + 3*time.Second + 500*time.Millisecond},
+		{ID: 1, TotalTime: 30*time.Minute + 12*time.Second},
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "Text",
+			file: "result_table.txt",
+			content: "[Finished] 2 00:29:03.500 {00:29:03.500, 2.095} {,} {00:00:00.000, 0.000} 5/5 [XXXXX]\n" +
+				"[Finished] 1 00:30:12.000 {00:30:12.000, 2.015} {,} {00:00:00.000, 0.000} 5/5 [XXXXX]\n" +
+				"[NotFinished] 3 NotFinished (Lost in the forest) {,} {,} {00:00:00.000, 0.000} 0/0\n",
+		},
+		{
+			name: "JSON",
+			file: "result_table.json",
+			content: `[
+				{"id": 2, "status": "Finished", "totalTime": "00:29:03.500"},
+				{"id": 1, "status": "Finished", "totalTime": "00:30:12.000"},
+				{"id": 3, "status": "NotFinished", "comment": "Lost in the forest"}
+			]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create previous results file: %v", err)
+			}
+			got, err := LoadPreviousResults(path)
+			if err != nil {
+				t.Fatalf("LoadPreviousResults() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadPreviousResults() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRaceEngine_Pursuit(t *testing.T) {
+	config := newTestConfig(t)
+	config.Format = FormatPursuit
+	config.pursuitGaps = pursuitGaps([]PreviousResult{
+		{ID: 1, TotalTime: 30 * time.Minute},
+		{ID: 2, TotalTime: 29 * time.Minute},
+	})
+	engine := NewRaceEngine(config)
+	got := applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 1 2",
+	)
+	want := []string{
+		"[09:00:00.000] The competitor(1) registered",
+		"[09:00:00.000] The start time for the competitor(1) was set by pursuit to 09:31:00.000",
+		"[09:00:01.000] The competitor(2) registered",
+		"[09:00:01.000] The start time for the competitor(2) was set by pursuit to 09:30:00.000",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+
+	event, _ := ParseEvent("[09:10:00.000] 2 1 09:45:00.000")
+	engine.Apply(event)
+	if v := engine.Violations(); len(v) != 1 || v[0].Code != CodeWrongState {
+		t.Errorf("Violations() = %v, want the draw rejected", v)
+	}
+
+	// Competitor 1 starts a minute behind but crosses the line first.
+	applyLines(t, engine,
+		"[09:30:00.500] 4 2",
+		"[09:31:00.500] 4 1",
+		"[09:50:00.000] 10 1",
+		"[09:50:01.000] 10 2",
+		"[10:09:00.000] 10 1",
+		"[10:09:01.000] 10 2",
+	)
+	standings := engine.Snapshot()
+	SortStandings(config, standings)
+	if standings[0].ID != 1 || standings[1].ID != 2 {
+		t.Errorf("standings = %d, %d, want 1, 2 by finish order", standings[0].ID, standings[1].ID)
+	}
+}
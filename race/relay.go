@@ -0,0 +1,187 @@
+package race
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Team is a relay team. Legs lists the competitor IDs of its athletes in
+// the order they ski.
+type Team struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Legs []int  `json:"legs"`
+}
+
+// relayLeg places an athlete in a relay.
+type relayLeg struct {
+	team *Team
+	// leg is the athlete's leg, from 1.
+	leg int
+}
+
+// next returns the competitor ID skiing the following leg, or false on the
+// last leg.
+func (l relayLeg) next() (int, bool) {
+	if l.leg >= len(l.team.Legs) {
+		return 0, false
+	}
+	return l.team.Legs[l.leg], true
+}
+
+func relayLegs(teams []Team) (map[int]relayLeg, error) {
+	if len(teams) == 0 {
+		return nil, fmt.Errorf("relay format requires teams")
+	}
+	legs := make(map[int]relayLeg)
+	teamIDs := make(map[int]bool)
+	for i := range teams {
+		team := &teams[i]
+		if teamIDs[team.ID] {
+			return nil, fmt.Errorf("duplicate relay team %d", team.ID)
+		}
+		teamIDs[team.ID] = true
+		if len(team.Legs) == 0 {
+			return nil, fmt.Errorf("relay team %d has no legs", team.ID)
+		}
+		for j, id := range team.Legs {
+			if other, ok := legs[id]; ok {
+				return nil, fmt.Errorf("competitor %d is on relay team %d and team %d", id, other.team.ID, team.ID)
+			}
+			legs[id] = relayLeg{team: team, leg: j + 1}
+		}
+	}
+	return legs, nil
+}
+
+// TeamResult is one relay team's result. Legs holds the athletes in leg
+// order; a leg whose athlete never registered has a zero Competitor.
+type TeamResult struct {
+	Team   Team
+	Status CompetitorStatus
+	Legs   []Competitor
+	// TotalTime is the time from the start to the last leg's finish plus
+	// every leg's time penalty, or zero if the team did not finish.
+	TotalTime time.Duration
+	// FinishOrder is the last leg's Competitor.FinishOrder.
+	FinishOrder int
+}
+
+// TeamStandings groups competitors into their relay teams and orders the
+// teams like SortStandings: by status, then finishers by total time with
+// photo-finish ties in crossing order, then by team ID.
+func TeamStandings(config *Config, competitors []Competitor) []TeamResult {
+	byID := make(map[int]Competitor, len(competitors))
+	for _, c := range competitors {
+		byID[c.ID] = c
+	}
+
+	results := make([]TeamResult, 0, len(config.Teams))
+	for _, team := range config.Teams {
+		result := TeamResult{Team: team, Status: StatusRegistered}
+		var penalty time.Duration
+		for _, id := range team.Legs {
+			leg := byID[id]
+			result.Legs = append(result.Legs, leg)
+			penalty += leg.TimePenalty
+		}
+		result.Status = teamStatus(result.Legs)
+		if result.Status == StatusFinished {
+			last := result.Legs[len(result.Legs)-1]
+			result.TotalTime = last.FinishTime.Sub(config.parsedStart) + penalty
+			result.FinishOrder = last.FinishOrder
+		}
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		ri := results[i]
+		rj := results[j]
+		if statusOrder[ri.Status] != statusOrder[rj.Status] {
+			return statusOrder[ri.Status] < statusOrder[rj.Status]
+		}
+		if ri.Status == StatusFinished && rj.Status == StatusFinished {
+			if ri.TotalTime != rj.TotalTime {
+				return ri.TotalTime < rj.TotalTime
+			}
+			return ri.FinishOrder < rj.FinishOrder
+		}
+		return ri.Team.ID < rj.Team.ID
+	})
+	return results
+}
+
+// teamStatus is the status of the first leg that has not finished, or
+// Finished if every leg has.
+func teamStatus(legs []Competitor) CompetitorStatus {
+	for _, leg := range legs {
+		if leg.Status != StatusFinished {
+			if leg.Status == "" {
+				return StatusRegistered
+			}
+			return leg.Status
+		}
+	}
+	return StatusFinished
+}
+
+// TeamResultLines formats one team's rows of the relay result table: the
+// team row followed by one ResultLine per leg.
+func TeamResultLines(config *Config, result *TeamResult) []string {
+	totalTimeStr := string(result.Status)
+	if result.Status == StatusFinished {
+		totalTimeStr = FormatDuration(result.TotalTime)
+	}
+	teamLine := fmt.Sprintf("[%s] Team %d %s", result.Status, result.Team.ID, totalTimeStr)
+	if result.Team.Name != "" {
+		teamLine += " (" + result.Team.Name + ")"
+	}
+
+	lines := []string{teamLine}
+	for i := range result.Legs {
+		leg := "not registered"
+		if result.Legs[i].ID != 0 {
+			leg = ResultLine(config, &result.Legs[i])
+		}
+		lines = append(lines, fmt.Sprintf("    Leg %d: %s", i+1, leg))
+	}
+	return lines
+}
+
+// TeamReport is the JSON form of one team in the relay result table.
+type TeamReport struct {
+	ID        int                `json:"id"`
+	Name      string             `json:"name,omitempty"`
+	Status    CompetitorStatus   `json:"status"`
+	TotalTime string             `json:"totalTime,omitempty"`
+	Legs      []CompetitorReport `json:"legs"`
+}
+
+// WriteTeamJSONReport writes the relay result table for teams, in the
+// order given, as an indented JSON array.
+func WriteTeamJSONReport(w io.Writer, config *Config, teams []TeamResult) error {
+	reports := make([]TeamReport, 0, len(teams))
+	for _, result := range teams {
+		report := TeamReport{
+			ID:     result.Team.ID,
+			Name:   result.Team.Name,
+			Status: result.Status,
+			Legs:   []CompetitorReport{},
+		}
+		if result.Status == StatusFinished {
+			report.TotalTime = FormatDuration(result.TotalTime)
+		}
+		for i := range result.Legs {
+			if result.Legs[i].ID != 0 {
+				report.Legs = append(report.Legs, NewCompetitorReport(config, &result.Legs[i]))
+			}
+		}
+		reports = append(reports, report)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reports)
+}
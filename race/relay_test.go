@@ -0,0 +1,136 @@
+package race
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelayLegs(t *testing.T) {
+	tests := []struct {
+		name       string
+		teams      []Team
+		wantErrStr string
+	}{
+		{"Valid", []Team{{ID: 1, Legs: []int{1, 2}}, {ID: 2, Legs: []int{3, 4}}}, ""},
+		{"No Teams", nil, "relay format requires teams"},
+		{"No Legs", []Team{{ID: 1}}, "relay team 1 has no legs"},
+		{"Duplicate Team", []Team{{ID: 1, Legs: []int{1}}, {ID: 1, Legs: []int{2}}}, "duplicate relay team 1"},
+		{"Shared Athlete", []Team{{ID: 1, Legs: []int{1, 2}}, {ID: 2, Legs: []int{2}}}, "competitor 2 is on relay team 1 and team 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := relayLegs(tt.teams)
+			if tt.wantErrStr == "" {
+				if err != nil {
+					t.Errorf("relayLegs() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrStr) {
+				t.Errorf("relayLegs() error = %v, want error containing %q", err, tt.wantErrStr)
+			}
+		})
+	}
+}
+
+func TestRaceEngine_Relay(t *testing.T) {
+	config := newTestConfig(t)
+	config.Format = FormatRelay
+	config.Laps = 1
+	config.RangesPerLap = 0
+	config.Teams = []Team{
+		{ID: 1, Name: "North", Legs: []int{1, 2}},
+		{ID: 2, Name: "South", Legs: []int{3, 4}},
+	}
+	legs, err := relayLegs(config.Teams)
+	if err != nil {
+		t.Fatalf("relayLegs() unexpected error = %v", err)
+	}
+	config.relayLegs = legs
+	engine := NewRaceEngine(config)
+
+	registered := applyLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 1 2",
+		"[09:00:02.000] 1 3",
+		"[09:00:03.000] 1 4",
+	)
+	wantRegistered := []string{
+		"[09:00:00.000] The competitor(1) registered",
+		"[09:00:00.000] The start time for the competitor(1) was set by relay start to 09:30:00.000",
+		"[09:00:01.000] The competitor(2) registered",
+		"[09:00:02.000] The competitor(3) registered",
+		"[09:00:02.000] The start time for the competitor(3) was set by relay start to 09:30:00.000",
+		"[09:00:03.000] The competitor(4) registered",
+	}
+	if !reflect.DeepEqual(registered, wantRegistered) {
+		t.Errorf("registration log = %q, want %q", registered, wantRegistered)
+	}
+	event, _ := ParseEvent("[09:00:04.000] 1 9")
+	var v *Violation
+	if _, err := engine.Apply(event); !errors.As(err, &v) || v.Code != CodeWrongState {
+		t.Errorf("Apply(%q) error = %v, want athletes outside the teams rejected", event.RawLine, err)
+	}
+
+	got := applyLines(t, engine,
+		"[09:50:00.000] 10 1",
+		"[09:51:00.000] 10 3",
+		"[10:09:00.000] 10 4",
+		"[10:10:00.000] 10 2",
+	)
+	want := []string{
+		"[09:50:00.000] The competitor(1) has started",
+		"[09:50:00.000] The competitor(1) ended the main lap",
+		"[09:50:00.000] The competitor(1) has finished",
+		"[09:50:00.000] The competitor(1) handed off to the competitor(2)",
+		"[09:50:00.000] The competitor(2) has started",
+		"[09:51:00.000] The competitor(3) has started",
+		"[09:51:00.000] The competitor(3) ended the main lap",
+		"[09:51:00.000] The competitor(3) has finished",
+		"[09:51:00.000] The competitor(3) handed off to the competitor(4)",
+		"[09:51:00.000] The competitor(4) has started",
+		"[10:09:00.000] The competitor(4) ended the main lap",
+		"[10:09:00.000] The competitor(4) has finished",
+		"[10:10:00.000] The competitor(2) ended the main lap",
+		"[10:10:00.000] The competitor(2) has finished",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+
+	teams := TeamStandings(config, engine.Snapshot())
+	var lines []string
+	for i := range teams {
+		lines = append(lines, TeamResultLines(config, &teams[i])...)
+	}
+	wantLines := []string{
+		"[Finished] Team 2 00:39:00.000 (South)",
+		"    Leg 1: [Finished] 3 00:21:00.000 {00:21:00.000, 2.898} {00:00:00.000, 0.000} 0/0",
+		"    Leg 2: [Finished] 4 00:18:00.000 {00:18:00.000, 3.381} {00:00:00.000, 0.000} 0/0",
+		"[Finished] Team 1 00:40:00.000 (North)",
+		"    Leg 1: [Finished] 1 00:20:00.000 {00:20:00.000, 3.042} {00:00:00.000, 0.000} 0/0",
+		"    Leg 2: [Finished] 2 00:20:00.000 {00:20:00.000, 3.042} {00:00:00.000, 0.000} 0/0",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("TeamResultLines() = %q, want %q", lines, wantLines)
+	}
+}
+
+func TestTeamStandings_Unfinished(t *testing.T) {
+	config := newTestConfig(t)
+	config.Teams = []Team{{ID: 1, Legs: []int{1, 2, 3}}}
+	competitors := []Competitor{
+		{ID: 1, Status: StatusFinished},
+		{ID: 2, Status: StatusNotFinished},
+	}
+	teams := TeamStandings(config, competitors)
+	if teams[0].Status != StatusNotFinished || teams[0].TotalTime != 0 {
+		t.Errorf("team status = %s, total time = %v, want %s and no time", teams[0].Status, teams[0].TotalTime, StatusNotFinished)
+	}
+	lines := TeamResultLines(config, &teams[0])
+	if want := "    Leg 3: not registered"; lines[3] != want {
+		t.Errorf("TeamResultLines()[3] = %q, want %q", lines[3], want)
+	}
+}
@@ -0,0 +1,159 @@
+package race
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var statusOrder = map[CompetitorStatus]int{
+	StatusFinished:     0,
+	StatusNotFinished:  1,
+	StatusNotStarted:   2,
+	StatusDisqualified: 3,
+	StatusOnLap:        4,
+	StatusInPenalty:    4,
+	StatusOnRange:      4,
+	StatusStarted:      4,
+	StatusOnStartLine:  4,
+	StatusScheduled:    4,
+	StatusRegistered:   4,
+}
+
+// SortStandings orders competitors for the final report: by status, then
+// finishers by total time (by finish order in a pursuit or mass start),
+// then everyone else by ID.
+func SortStandings(config *Config, competitors []Competitor) {
+	sort.Slice(competitors, func(i, j int) bool {
+		ci := competitors[i]
+		cj := competitors[j]
+
+		statusI := statusOrder[ci.Status]
+		statusJ := statusOrder[cj.Status]
+
+		if statusI != statusJ {
+			return statusI < statusJ
+		}
+
+		if ci.Status == StatusFinished && cj.Status == StatusFinished {
+			if config.Format == FormatPursuit || config.Format == FormatMassStart {
+				finishI := ci.FinishTime.Add(ci.TimePenalty)
+				finishJ := cj.FinishTime.Add(cj.TimePenalty)
+				if !finishI.Equal(finishJ) {
+					return finishI.Before(finishJ)
+				}
+				// Photo finish: equal times are ranked in crossing order.
+				return ci.FinishOrder < cj.FinishOrder
+			}
+			return ci.TotalTime() < cj.TotalTime()
+		}
+
+		return ci.ID < cj.ID
+	})
+}
+
+// ResultLine formats one competitor's row of the resulting table.
+func ResultLine(config *Config, c *Competitor) string {
+	statusStr := ""
+	totalTimeStr := ""
+
+	switch c.Status {
+	case StatusFinished:
+		statusStr = "[Finished]"
+		if !c.FinishTime.IsZero() && !c.ScheduledStartTime.IsZero() {
+			totalTimeStr = FormatDuration(c.TotalTime())
+		} else {
+			totalTimeStr = "ERR: Missing Times"
+		}
+	case StatusNotFinished:
+		statusStr = "[NotFinished]"
+		totalTimeStr = "NotFinished"
+		if c.Comment != "" {
+			totalTimeStr += " (" + c.Comment + ")"
+		}
+	case StatusNotStarted:
+		statusStr = "[NotStarted]"
+		totalTimeStr = "NotStarted"
+	case StatusDisqualified:
+		statusStr = "[Disqualified]"
+		totalTimeStr = "Disqualified"
+	default:
+		statusStr = fmt.Sprintf("[%s]", c.Status)
+		totalTimeStr = string(c.Status)
+	}
+
+	var lapDetails []string
+	for i := 0; i < config.Laps; i++ {
+		detail := "{,}"
+		if i < len(c.LapsCompleted) {
+			lap := c.LapsCompleted[i]
+			if lap.Duration() > 0 {
+				detail = fmt.Sprintf("{%s, %.3f}", FormatDuration(lap.Duration()), lap.AverageSpeed())
+			} else {
+				detail = fmt.Sprintf("{%s, 0.000}", FormatDuration(lap.Duration()))
+			}
+			if sectors := lap.Sectors(); len(sectors) > 0 {
+				var sectorDetails []string
+				for _, sector := range sectors {
+					sectorDetails = append(sectorDetails, fmt.Sprintf("{%s, %.3f}", FormatDuration(sector.Duration()), sector.AverageSpeed()))
+				}
+				detail += " [" + strings.Join(sectorDetails, " ") + "]"
+			}
+		}
+		lapDetails = append(lapDetails, detail)
+	}
+	lapsStr := strings.Join(lapDetails, " ")
+
+	var penaltyStr string
+	if config.Format == FormatIndividual {
+		penaltyStr = fmt.Sprintf("{+%s}", FormatDuration(c.TimePenalty))
+	} else {
+		totalPenaltyDuration, _, penaltyAvgSpeed := c.PenaltyTotals()
+		penaltyStr = fmt.Sprintf("{%s, %.3f}", FormatDuration(totalPenaltyDuration), penaltyAvgSpeed)
+	}
+
+	shootingStr := fmt.Sprintf("%d/%d", c.TotalHits, c.TotalShots)
+	if len(c.FiringRangeVisits) > 0 {
+		var patterns []string
+		for _, visit := range c.FiringRangeVisits {
+			patterns = append(patterns, visit.HitPattern())
+		}
+		shootingStr += " [" + strings.Join(patterns, " ") + "]"
+	}
+	if totals := c.PositionTotals(); len(totals) > 0 {
+		var positions []string
+		for _, total := range totals {
+			positions = append(positions, fmt.Sprintf("%s %d/%d", total.Position, total.Hits, total.Shots))
+		}
+		shootingStr += " (" + strings.Join(positions, ", ") + ")"
+	}
+
+	return fmt.Sprintf("%s %d %s %s %s %s",
+		statusStr,
+		c.ID,
+		totalTimeStr,
+		lapsStr,
+		penaltyStr,
+		shootingStr,
+	)
+}
+
+// ProgressionLine formats a competitor's running rank at each timing point,
+// with the places gained or lost since the previous one, e.g.
+// "1: shooting 1 4, lap 1 2 (+2), lap 2 3 (-1)". It returns "" if the
+// competitor has not passed any.
+func ProgressionLine(c *Competitor) string {
+	points := c.RankProgression()
+	if len(points) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, point := range points {
+		part := fmt.Sprintf("%s %d", point.Point, point.Rank)
+		if point.Change != 0 {
+			part += fmt.Sprintf(" (%+d)", point.Change)
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("%d: %s", c.ID, strings.Join(parts, ", "))
+}
@@ -0,0 +1,86 @@
+package race
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteLapsCSV writes one row per completed lap of every competitor, in the
+// order given.
+func WriteLapsCSV(w io.Writer, competitors []Competitor) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"competitor", "lap", "start", "end", "duration", "speed", "rank"})
+	for _, c := range competitors {
+		for _, lap := range c.LapsCompleted {
+			writer.Write([]string{
+				strconv.Itoa(c.ID),
+				strconv.Itoa(lap.Number),
+				formatClock(lap.StartTime),
+				formatClock(lap.EndTime),
+				FormatDuration(lap.Duration()),
+				fmt.Sprintf("%.3f", lap.AverageSpeed()),
+				strconv.Itoa(lap.Rank),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteSectorsCSV writes one row per sector of every completed lap with
+// checkpoints, in the order given. Sectors are numbered from 1 within the
+// lap.
+func WriteSectorsCSV(w io.Writer, competitors []Competitor) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"competitor", "lap", "sector", "from", "to", "start", "end", "duration", "speed"})
+	for _, c := range competitors {
+		for _, lap := range c.LapsCompleted {
+			for i, sector := range lap.Sectors() {
+				writer.Write([]string{
+					strconv.Itoa(c.ID),
+					strconv.Itoa(lap.Number),
+					strconv.Itoa(i + 1),
+					strconv.FormatFloat(sector.From, 'f', -1, 64),
+					strconv.FormatFloat(sector.To, 'f', -1, 64),
+					formatClock(sector.StartTime),
+					formatClock(sector.EndTime),
+					FormatDuration(sector.Duration()),
+					fmt.Sprintf("%.3f", sector.AverageSpeed()),
+				})
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteRangeVisitsCSV writes one row per completed firing range visit of
+// every competitor, in the order given. Visits are numbered from 1 in the
+// order the competitor made them.
+func WriteRangeVisitsCSV(w io.Writer, competitors []Competitor) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"competitor", "visit", "lap", "firing_line", "position", "enter", "exit", "hits", "shots", "rounds", "targets", "time_on_range", "rank"})
+	for _, c := range competitors {
+		for i, visit := range c.FiringRangeVisits {
+			writer.Write([]string{
+				strconv.Itoa(c.ID),
+				strconv.Itoa(i + 1),
+				strconv.Itoa(visit.Lap),
+				strconv.Itoa(visit.FiringLine),
+				string(visit.Position),
+				formatClock(visit.EnterTime),
+				formatClock(visit.ExitTime),
+				strconv.Itoa(visit.Hits),
+				strconv.Itoa(visit.Shots),
+				strconv.Itoa(visit.RoundsFired),
+				visit.HitPattern(),
+				FormatDuration(visit.Duration()),
+				strconv.Itoa(visit.Rank),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,68 @@
+package race
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteSplitsCSV(t *testing.T) {
+	config := newTestConfig(t)
+	config.Laps = 1
+	config.Checkpoints = []float64{1200, 2400}
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:05:59.867] 1 1",
+		"[09:15:00.841] 2 1 09:30:00.000",
+		"[09:30:01.005] 4 1",
+		"[09:40:00.000] 12 1 1",
+		"[09:49:31.659] 5 1 1",
+		"[09:49:33.123] 6 1 1",
+		"[09:49:34.650] 6 1 2",
+		"[09:49:35.937] 6 1 3",
+		"[09:49:36.100] 6 1 4",
+		"[09:49:37.364] 6 1 5",
+		"[09:49:38.339] 7 1",
+		"[09:55:00.000] 12 1 2",
+		"[09:59:03.872] 10 1",
+	)
+	competitors := engine.Snapshot()
+
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		want  string
+	}{
+		{
+			name:  "Laps",
+			write: func(buf *bytes.Buffer) error { return WriteLapsCSV(buf, competitors) },
+			want: "competitor,lap,start,end,duration,speed,rank\n" +
+				"1,1,09:30:01.005,09:59:03.872,00:29:02.867,2.095,1\n",
+		},
+		{
+			name:  "Range Visits",
+			write: func(buf *bytes.Buffer) error { return WriteRangeVisitsCSV(buf, competitors) },
+			want: "competitor,visit,lap,firing_line,position,enter,exit,hits,shots,rounds,targets,time_on_range,rank\n" +
+				"1,1,1,1,,09:49:31.659,09:49:38.339,5,5,5,XXXXX,00:00:06.680,1\n",
+		},
+		{
+			name:  "Sectors",
+			write: func(buf *bytes.Buffer) error { return WriteSectorsCSV(buf, competitors) },
+			want: "competitor,lap,sector,from,to,start,end,duration,speed\n" +
+				"1,1,1,0,1200,09:30:01.005,09:40:00.000,00:09:58.995,2.003\n" +
+				"1,1,2,1200,2400,09:40:00.000,09:55:00.000,00:15:00.000,1.333\n" +
+				"1,1,3,2400,3651,09:55:00.000,09:59:03.872,00:04:03.872,5.130\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatalf("unexpected error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
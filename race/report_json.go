@@ -0,0 +1,193 @@
+package race
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+)
+
+// CompetitorReport is the JSON form of one row of the resulting table.
+// Durations and clock times use the text table's "HH:MM:SS.sss" format;
+// speeds are in m/s.
+type CompetitorReport struct {
+	ID          int              `json:"id"`
+	Status      CompetitorStatus `json:"status"`
+	Team        int              `json:"team,omitempty"`
+	Leg         int              `json:"leg,omitempty"`
+	TotalTime   string           `json:"totalTime,omitempty"`
+	TimePenalty string           `json:"timePenalty,omitempty"`
+	Comment     string           `json:"comment,omitempty"`
+	Laps        []LapReport      `json:"laps"`
+	// Penalty is omitted in the individual format, where misses only add
+	// TimePenalty.
+	Penalty           *PenaltyReport     `json:"penalty,omitempty"`
+	FiringRangeVisits []RangeVisitReport `json:"firingRangeVisits"`
+	Hits              int                `json:"hits"`
+	Shots             int                `json:"shots"`
+	// Positions breaks Hits and Shots down by shooting position.
+	Positions []PositionReport `json:"positions,omitempty"`
+	// Progression is the running rank after each firing range visit and
+	// lap, in the order they were completed.
+	Progression []ProgressionReport `json:"progression,omitempty"`
+}
+
+type ProgressionReport struct {
+	Point  string `json:"point"`
+	Rank   int    `json:"rank"`
+	Change int    `json:"change"`
+}
+
+type PositionReport struct {
+	Position ShootingPosition `json:"position"`
+	Hits     int              `json:"hits"`
+	Shots    int              `json:"shots"`
+}
+
+type LapReport struct {
+	Number       int     `json:"number"`
+	Duration     string  `json:"duration"`
+	AverageSpeed float64 `json:"averageSpeed"`
+	Rank         int     `json:"rank,omitempty"`
+	// Sectors is omitted if no checkpoints were passed on the lap.
+	Sectors []SectorReport `json:"sectors,omitempty"`
+}
+
+type SectorReport struct {
+	From         float64 `json:"from"`
+	To           float64 `json:"to"`
+	Duration     string  `json:"duration"`
+	AverageSpeed float64 `json:"averageSpeed"`
+}
+
+type PenaltyReport struct {
+	Laps         int     `json:"laps"`
+	Distance     float64 `json:"distance"`
+	Duration     string  `json:"duration"`
+	AverageSpeed float64 `json:"averageSpeed"`
+}
+
+type RangeVisitReport struct {
+	Lap         int              `json:"lap"`
+	FiringLine  int              `json:"firingLine"`
+	Position    ShootingPosition `json:"position,omitempty"`
+	EnterTime   string           `json:"enterTime"`
+	ExitTime    string           `json:"exitTime"`
+	Hits        int              `json:"hits"`
+	Shots       int              `json:"shots"`
+	SpareRounds int              `json:"spareRounds,omitempty"`
+	RoundsFired int              `json:"roundsFired"`
+	Rank        int              `json:"rank,omitempty"`
+	// Targets lists the targets hit in hit order; Pattern shows every
+	// target as 'X' (hit) or '-' (standing).
+	Targets []int  `json:"targets"`
+	Pattern string `json:"pattern"`
+}
+
+// NewCompetitorReport builds the report row for c.
+func NewCompetitorReport(config *Config, c *Competitor) CompetitorReport {
+	report := CompetitorReport{
+		ID:                c.ID,
+		Status:            c.Status,
+		Team:              c.Team,
+		Leg:               c.Leg,
+		Comment:           c.Comment,
+		Laps:              []LapReport{},
+		FiringRangeVisits: []RangeVisitReport{},
+		Hits:              c.TotalHits,
+		Shots:             c.TotalShots,
+	}
+	if c.Status == StatusFinished && c.TotalTime() > 0 {
+		report.TotalTime = FormatDuration(c.TotalTime())
+	}
+	if c.TimePenalty > 0 {
+		report.TimePenalty = FormatDuration(c.TimePenalty)
+	}
+
+	for _, lap := range c.LapsCompleted {
+		lapReport := LapReport{
+			Number:       lap.Number,
+			Duration:     FormatDuration(lap.Duration()),
+			AverageSpeed: roundSpeed(lap.AverageSpeed()),
+			Rank:         lap.Rank,
+		}
+		for _, sector := range lap.Sectors() {
+			lapReport.Sectors = append(lapReport.Sectors, SectorReport{
+				From:         sector.From,
+				To:           sector.To,
+				Duration:     FormatDuration(sector.Duration()),
+				AverageSpeed: roundSpeed(sector.AverageSpeed()),
+			})
+		}
+		report.Laps = append(report.Laps, lapReport)
+	}
+
+	if config.Format != FormatIndividual {
+		duration, distance, averageSpeed := c.PenaltyTotals()
+		report.Penalty = &PenaltyReport{
+			Laps:         len(c.PenaltyLapsCompleted),
+			Distance:     distance,
+			Duration:     FormatDuration(duration),
+			AverageSpeed: roundSpeed(averageSpeed),
+		}
+	}
+
+	for _, visit := range c.FiringRangeVisits {
+		report.FiringRangeVisits = append(report.FiringRangeVisits, RangeVisitReport{
+			Lap:         visit.Lap,
+			FiringLine:  visit.FiringLine,
+			Position:    visit.Position,
+			EnterTime:   formatClock(visit.EnterTime),
+			ExitTime:    formatClock(visit.ExitTime),
+			Hits:        visit.Hits,
+			Shots:       visit.Shots,
+			SpareRounds: visit.SpareRounds,
+			RoundsFired: visit.RoundsFired,
+			Rank:        visit.Rank,
+			Targets:     append([]int{}, visit.HitTargets...),
+			Pattern:     visit.HitPattern(),
+		})
+	}
+
+	for _, total := range c.PositionTotals() {
+		report.Positions = append(report.Positions, PositionReport{
+			Position: total.Position,
+			Hits:     total.Hits,
+			Shots:    total.Shots,
+		})
+	}
+
+	for _, point := range c.RankProgression() {
+		report.Progression = append(report.Progression, ProgressionReport{
+			Point:  point.Point,
+			Rank:   point.Rank,
+			Change: point.Change,
+		})
+	}
+
+	return report
+}
+
+// WriteJSONReport writes the resulting table for competitors, in the order
+// given, as an indented JSON array.
+func WriteJSONReport(w io.Writer, config *Config, competitors []Competitor) error {
+	reports := make([]CompetitorReport, 0, len(competitors))
+	for i := range competitors {
+		reports = append(reports, NewCompetitorReport(config, &competitors[i]))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reports)
+}
+
+// roundSpeed rounds to the three decimals the text table shows.
+func roundSpeed(speed float64) float64 {
+	return math.Round(speed*1000) / 1000
+}
+
+func formatClock(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
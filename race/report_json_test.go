@@ -0,0 +1,59 @@
+package race
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteJSONReport(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	applyLines(t, engine,
+		"[09:05:59.867] 1 1",
+		"[09:15:00.841] 2 1 09:30:00.000",
+		"[09:30:01.005] 4 1",
+		"[09:49:31.659] 5 1 1",
+		"[09:49:33.123] 6 1 1",
+		"[09:49:34.650] 6 1 2",
+		"[09:49:35.937] 6 1 4",
+		"[09:49:37.364] 6 1 5",
+		"[09:49:38.339] 7 1",
+		"[09:49:55.915] 8 1",
+		"[09:51:48.391] 9 1",
+		"[09:59:03.872] 10 1",
+		"[09:59:05.321] 11 1 Lost in the forest",
+	)
+
+	var buf bytes.Buffer
+	if err := WriteJSONReport(&buf, engine.config, engine.Snapshot()); err != nil {
+		t.Fatalf("WriteJSONReport() unexpected error = %v", err)
+	}
+
+	var got []CompetitorReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	want := []CompetitorReport{{
+		ID:      1,
+		Status:  StatusNotFinished,
+		Comment: "Lost in the forest",
+		Laps: []LapReport{
+			{Number: 1, Duration: "00:29:02.867", AverageSpeed: 2.095, Rank: 1},
+		},
+		Penalty: &PenaltyReport{Laps: 1, Distance: 50, Duration: "00:01:52.476", AverageSpeed: 0.445},
+		FiringRangeVisits: []RangeVisitReport{
+			{Lap: 1, FiringLine: 1, EnterTime: "09:49:31.659", ExitTime: "09:49:38.339", Hits: 4, Shots: 5, RoundsFired: 5, Rank: 1, Targets: []int{1, 2, 4, 5}, Pattern: "XX-XX"},
+		},
+		Hits:  4,
+		Shots: 5,
+		Progression: []ProgressionReport{
+			{Point: "shooting 1", Rank: 1},
+			{Point: "lap 1", Rank: 1},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteJSONReport() = %+v, want %+v", got, want)
+	}
+}
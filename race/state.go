@@ -0,0 +1,52 @@
+package race
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// state is the saved form of a finished run: the config it ran with and
+// every competitor, from which the result tables can be rebuilt.
+type state struct {
+	Config      Config       `json:"config"`
+	Competitors []Competitor `json:"competitors"`
+}
+
+// WriteState saves config and competitors as JSON for LoadState.
+func WriteState(w io.Writer, config *Config, competitors []Competitor) error {
+	saved := state{Config: *config, Competitors: competitors}
+	// The state may be read from another directory.
+	if saved.Config.PreviousResults != "" && !filepath.IsAbs(saved.Config.PreviousResults) {
+		path, err := filepath.Abs(filepath.Join(config.dir, saved.Config.PreviousResults))
+		if err != nil {
+			return fmt.Errorf("error resolving previous results path: %w", err)
+		}
+		saved.Config.PreviousResults = path
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(saved)
+}
+
+// LoadState reads a state written by WriteState, validating its config as
+// LoadConfig does. A pursuit's previous results are not read again: the
+// saved scheduled start times already hold the gaps.
+func LoadState(path string) (*Config, []Competitor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening state file: %w", err)
+	}
+	defer file.Close()
+
+	var saved state
+	if err := json.NewDecoder(file).Decode(&saved); err != nil {
+		return nil, nil, fmt.Errorf("error parsing state JSON: %w", err)
+	}
+	if err := saved.Config.validate(filepath.Dir(path), false); err != nil {
+		return nil, nil, err
+	}
+	return &saved.Config, saved.Competitors, nil
+}
@@ -0,0 +1,109 @@
+package race
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteState(t *testing.T) {
+	config := newTestConfig(t)
+	engine := NewRaceEngine(config)
+	applyLines(t, engine,
+		"[09:05:59.867] 1 1",
+		"[09:15:00.841] 2 1 09:30:00.000",
+		"[09:30:01.005] 4 1",
+		"[09:49:31.659] 5 1 1",
+		"[09:49:33.123] 6 1 1",
+		"[09:49:38.339] 7 1",
+	)
+	engine.Finish()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create state file: %v", err)
+	}
+	want := engine.Snapshot()
+	if err := WriteState(file, config, want); err != nil {
+		t.Fatalf("WriteState() unexpected error = %v", err)
+	}
+	file.Close()
+
+	gotConfig, got, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() unexpected error = %v", err)
+	}
+	if !gotConfig.parsedStart.Equal(config.parsedStart) || gotConfig.Laps != config.Laps {
+		t.Errorf("LoadState() config = %+v, want %+v", gotConfig, config)
+	}
+	for i := range want {
+		if ResultLine(gotConfig, &got[i]) != ResultLine(config, &want[i]) {
+			t.Errorf("ResultLine() after LoadState() = %q, want %q", ResultLine(gotConfig, &got[i]), ResultLine(config, &want[i]))
+		}
+		if !reflect.DeepEqual(got[i].FiringRangeVisits, want[i].FiringRangeVisits) {
+			t.Errorf("FiringRangeVisits after LoadState() = %+v, want %+v", got[i].FiringRangeVisits, want[i].FiringRangeVisits)
+		}
+	}
+}
+
+func TestLoadState_Pursuit(t *testing.T) {
+	dir := t.TempDir()
+	resultsPath := filepath.Join(dir, "prev.txt")
+	if err := os.WriteFile(resultsPath, []byte("[Finished] 1 00:30:00.000 {,} {,} {00:00:00.000, 0.000} 5/5\n"), 0644); err != nil {
+		t.Fatalf("Failed to create previous results file: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{
+		"laps": 1,
+		"lapLen": 3651,
+		"penaltyLen": 50,
+		"firingLines": 1,
+		"start": "09:30:00",
+		"startDelta": "00:00:30",
+		"format": "pursuit",
+		"previousResults": "prev.txt"
+	}`), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	// Load the config by a relative path, as "run config.json" does.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	config, err := LoadConfig(filepath.Base(configPath))
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	engine := NewRaceEngine(config)
+	applyLines(t, engine, "[09:00:00.000] 1 1")
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	file, err := os.Create(statePath)
+	if err != nil {
+		t.Fatalf("Failed to create state file: %v", err)
+	}
+	if err := WriteState(file, config, engine.Snapshot()); err != nil {
+		t.Fatalf("WriteState() unexpected error = %v", err)
+	}
+	file.Close()
+	if err := os.Remove(resultsPath); err != nil {
+		t.Fatalf("Failed to remove previous results file: %v", err)
+	}
+
+	gotConfig, got, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState() unexpected error = %v, want previous results not read again", err)
+	}
+	if gotConfig.PreviousResults != resultsPath {
+		t.Errorf("LoadState() previous results = %q, want %q", gotConfig.PreviousResults, resultsPath)
+	}
+	if want := "09:30:00.000"; got[0].ScheduledStartTime.Format(timeLayout) != want {
+		t.Errorf("scheduled start = %s, want %s from the saved state", got[0].ScheduledStartTime.Format(timeLayout), want)
+	}
+}
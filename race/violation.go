@@ -0,0 +1,114 @@
+package race
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ViolationCode classifies why an event was rejected.
+type ViolationCode string
+
+const (
+	CodeUnknownCompetitor ViolationCode = "unknown_competitor"
+	CodeAlreadyFinished   ViolationCode = "already_finished"
+	CodeWrongState        ViolationCode = "wrong_state"
+	CodeMissingParam      ViolationCode = "missing_param"
+	CodeBadTime           ViolationCode = "bad_time"
+	CodeUnknownEvent      ViolationCode = "unknown_event"
+	CodeBadTarget         ViolationCode = "bad_target"
+	CodeDuplicateHit      ViolationCode = "duplicate_hit"
+	CodeBadFiringLine     ViolationCode = "bad_firing_line"
+	CodeSkippedRange      ViolationCode = "skipped_range"
+	CodeRepeatedRange     ViolationCode = "repeated_range"
+	CodeMissingPenalty    ViolationCode = "missing_penalty"
+	CodeShortPenalty      ViolationCode = "short_penalty"
+	CodeBadCheckpoint     ViolationCode = "bad_checkpoint"
+	CodeBadRounds         ViolationCode = "bad_rounds"
+	CodeMalformed         ViolationCode = "malformed"
+)
+
+// Violation describes an incoming event that the engine rejected, one it
+// applied but flagged as breaking the race rules (such as the spec's
+// guarantee that events arrive in time order), or a line that could not be
+// parsed as an event at all.
+type Violation struct {
+	Line    int
+	RawLine string
+	// Event is nil if the line could not be parsed.
+	Event *Event
+	// Status is the competitor's status when the event arrived, or empty
+	// if the competitor was not registered.
+	Status CompetitorStatus
+	Code   ViolationCode
+	Reason string
+}
+
+func newViolation(event *Event, status CompetitorStatus, code ViolationCode, reason string) Violation {
+	return Violation{
+		Line:    event.Line,
+		RawLine: event.RawLine,
+		Event:   event,
+		Status:  status,
+		Code:    code,
+		Reason:  reason,
+	}
+}
+
+// NewParseViolation records a line that ParseEvent rejected with err.
+func NewParseViolation(line int, rawLine string, err error) Violation {
+	code := CodeMalformed
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		code = CodeBadTime
+	}
+	return Violation{Line: line, RawLine: rawLine, Code: code, Reason: err.Error()}
+}
+
+func (v *Violation) Error() string {
+	if v.Event == nil {
+		return fmt.Sprintf("line %d: [%s] %q: %s", v.Line, v.Code, v.RawLine, v.Reason)
+	}
+	status := string(v.Status)
+	if status == "" {
+		status = "Unregistered"
+	}
+	return fmt.Sprintf("line %d: [%s] competitor(%d) %s: event %q: %s", v.Line, v.Code, v.Event.CompetitorID, status, v.RawLine, v.Reason)
+}
+
+type diagnostic struct {
+	Line         int              `json:"line"`
+	Event        string           `json:"event"`
+	Time         string           `json:"time,omitempty"`
+	EventID      int              `json:"eventId,omitempty"`
+	CompetitorID int              `json:"competitorId,omitempty"`
+	Status       CompetitorStatus `json:"status,omitempty"`
+	Code         ViolationCode    `json:"code"`
+	Reason       string           `json:"reason"`
+}
+
+// WriteDiagnostics writes violations as an indented JSON array, one object
+// per dropped or suspicious event.
+func WriteDiagnostics(w io.Writer, violations []Violation) error {
+	diagnostics := make([]diagnostic, 0, len(violations))
+	for _, v := range violations {
+		d := diagnostic{
+			Line:   v.Line,
+			Event:  v.RawLine,
+			Status: v.Status,
+			Code:   v.Code,
+			Reason: v.Reason,
+		}
+		if v.Event != nil {
+			d.Time = formatClock(v.Event.Time)
+			d.EventID = v.Event.ID
+			d.CompetitorID = v.Event.CompetitorID
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diagnostics)
+}
@@ -0,0 +1,68 @@
+package race
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewParseViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want ViolationCode
+	}{
+		{"Bad Time", "[09:05:59] 1 1", CodeBadTime},
+		{"Too Few Parts", "[09:05:59.867] 1", CodeMalformed},
+		{"Bad Event ID", "[09:05:59.867] x 1", CodeMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseEvent(tt.line)
+			if err == nil {
+				t.Fatalf("ParseEvent(%q) expected error, got nil", tt.line)
+			}
+			v := NewParseViolation(3, tt.line, err)
+			if v.Code != tt.want || v.Line != 3 || v.RawLine != tt.line || v.Event != nil {
+				t.Errorf("NewParseViolation() = %+v, want code %s on line 3", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	engine := NewRaceEngine(newTestConfig(t))
+	violations := applyNumberedLines(t, engine,
+		"[09:00:00.000] 1 1",
+		"[09:00:01.000] 7 1",
+		"[09:00:02.000] 4 2",
+	)
+	_, err := ParseEvent("[09:00:03] 1 3")
+	violations = append(violations, NewParseViolation(4, "[09:00:03] 1 3", err))
+
+	var buf bytes.Buffer
+	if err := WriteDiagnostics(&buf, violations); err != nil {
+		t.Fatalf("WriteDiagnostics() unexpected error = %v", err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("diagnostics are not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	var codes []string
+	for _, d := range got {
+		codes = append(codes, d["code"].(string))
+	}
+	want := []string{string(CodeWrongState), string(CodeUnknownCompetitor), string(CodeBadTime)}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("diagnostic codes = %v, want %v", codes, want)
+	}
+	if got[0]["status"] != string(StatusRegistered) || got[0]["competitorId"] != float64(1) || got[0]["line"] != float64(2) {
+		t.Errorf("first diagnostic = %v, want line 2 for registered competitor 1", got[0])
+	}
+	if _, ok := got[2]["eventId"]; ok {
+		t.Errorf("unparsable line must not report an event ID: %v", got[2])
+	}
+}
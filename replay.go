@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"solid-system/race"
+)
+
+// replayCommand steps through an events file one event at a time, printing
+// each event and the log entries it produced. It waits for Enter between
+// events ("q" quits), or for --delay if set.
+func replayCommand(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	delay := flags.Duration("delay", 0, "advance automatically after this long instead of waiting for Enter")
+	standings := flags.Bool("standings", false, "print the standings after every event")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . replay [flags] <config> <events>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 1
+	}
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+
+	run := newRaceRun(config)
+	events, err := readEvents(flags.Arg(1), run)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var step func() bool
+	if *delay > 0 {
+		step = func() bool {
+			time.Sleep(*delay)
+			return true
+		}
+	} else {
+		step = promptStep(os.Stdin, os.Stdout)
+	}
+	replayEvents(os.Stdout, run, events, *standings, step)
+	return 0
+}
+
+// replayEvents applies events to run one at a time, writing each event and
+// its log entries to w and calling step before the next one; it stops early
+// if step returns false. The final standings are written at the end.
+func replayEvents(w io.Writer, run *raceRun, events []*race.Event, standings bool, step func() bool) {
+	for i, event := range events {
+		if i > 0 && !step() {
+			break
+		}
+		fmt.Fprintf(w, "> %s\n", event.RawLine)
+		for _, logEntry := range run.apply(event) {
+			fmt.Fprintln(w, logEntry)
+		}
+		if standings {
+			printStandings(w, run, run.config)
+		}
+	}
+	for _, logEntry := range run.finish() {
+		fmt.Fprintln(w, logEntry)
+	}
+	printStandings(w, run, run.config)
+}
+
+// promptStep returns a step function that waits for a line on in and
+// stops on "q" or at the end of input.
+func promptStep(in io.Reader, out io.Writer) func() bool {
+	scanner := bufio.NewScanner(in)
+	return func() bool {
+		fmt.Fprint(out, "-- Enter: next event, q: quit -- ")
+		if !scanner.Scan() {
+			return false
+		}
+		return strings.TrimSpace(scanner.Text()) != "q"
+	}
+}
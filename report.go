@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"solid-system/race"
+)
+
+// reportCommand rebuilds the result table, and optionally the CSV exports,
+// from a state saved by run --state.
+func reportCommand(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "text", "result table format: text or json")
+	resultTablePath := flags.String("result-table", "", "also write the result table to this `file`")
+	lapsCSV := flags.String("laps-csv", "", "also write per-lap splits as CSV to this `file`")
+	rangesCSV := flags.String("ranges-csv", "", "also write per-visit firing range data as CSV to this `file`")
+	sectorsCSV := flags.String("sectors-csv", "", "also write per-sector splits between checkpoints as CSV to this `file`")
+	progression := flags.Bool("progression", false, "add each competitor's running rank after every lap and firing range visit to the text result table")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . report [flags] <state>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("unknown report format %q\n", *format)
+		flags.Usage()
+		return 1
+	}
+
+	config, competitors, err := race.LoadState(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("error loading state: %v\n", err)
+		return 1
+	}
+
+	if *lapsCSV != "" {
+		if err := writeCSV(*lapsCSV, sortedCompetitors(config, competitors), race.WriteLapsCSV); err != nil {
+			fmt.Printf("error writing laps CSV file: %v\n", err)
+			return 1
+		}
+	}
+	if *rangesCSV != "" {
+		if err := writeCSV(*rangesCSV, sortedCompetitors(config, competitors), race.WriteRangeVisitsCSV); err != nil {
+			fmt.Printf("error writing firing range CSV file: %v\n", err)
+			return 1
+		}
+	}
+	if *sectorsCSV != "" {
+		if err := writeCSV(*sectorsCSV, sortedCompetitors(config, competitors), race.WriteSectorsCSV); err != nil {
+			fmt.Printf("error writing sectors CSV file: %v\n", err)
+			return 1
+		}
+	}
+
+	var report bytes.Buffer
+	if *format == "json" {
+		if err := writeJSONTable(&report, config, competitors); err != nil {
+			fmt.Printf("error encoding result table: %v\n", err)
+			return 1
+		}
+	} else {
+		lines := standingsLines(config, competitors)
+		if *progression {
+			lines = append(lines, progressionLines(config, competitors)...)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(&report, line)
+		}
+	}
+	os.Stdout.Write(report.Bytes())
+	if *resultTablePath != "" {
+		if err := writeBytes(*resultTablePath, report.Bytes()); err != nil {
+			fmt.Printf("error writing result table file: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
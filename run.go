@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"solid-system/race"
+)
+
+// runCommand processes an events file and writes the output log, the
+// events log and the result table.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "tail the events file and process events as they are written, until interrupted")
+	format := flags.String("format", "text", "final report format: text or json")
+	outputDir := flags.String("output-dir", ".", "write the output files to this `directory`; relative file names below are resolved against it")
+	output := flags.String("output", "both", "where the output log and result table go: both, stdout or files")
+	outputLogPath := flags.String("output-log", "output_log.txt", "write the output log to this `file`")
+	eventsLogPath := flags.String("events-log", "output_events.txt", "write the incoming and outgoing events to this `file`")
+	resultTablePath := flags.String("result-table", "", "write the result table to this `file` (default result_table.txt, or result_table.json with --format json)")
+	statePath := flags.String("state", "", "also save the final state to this `file` for the report command")
+	lapsCSV := flags.String("laps-csv", "", "also write per-lap splits as CSV to this `file`")
+	rangesCSV := flags.String("ranges-csv", "", "also write per-visit firing range data as CSV to this `file`")
+	sectorsCSV := flags.String("sectors-csv", "", "also write per-sector splits between checkpoints as CSV to this `file`")
+	diagnostics := flags.String("diagnostics", "", "write every ignored or rejected event with its reason code as JSON to this `file`")
+	progression := flags.Bool("progression", false, "add each competitor's running rank after every lap and firing range visit to the text result table")
+	strict := flags.Bool("strict", false, "report every unparsable, out-of-order or rejected event and exit with an error instead of writing results")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . [run] [flags] <config> <events>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("unknown report format %q\n", *format)
+		flags.Usage()
+		return 1
+	}
+	if *output != "both" && *output != "stdout" && *output != "files" {
+		fmt.Printf("unknown output mode %q\n", *output)
+		flags.Usage()
+		return 1
+	}
+	toStdout := *output != "files"
+	toFiles := *output != "stdout"
+	if *resultTablePath == "" {
+		*resultTablePath = "result_table.txt"
+		if *format == "json" {
+			*resultTablePath = "result_table.json"
+		}
+	}
+
+	// --output stdout writes no files unless one is asked for by name.
+	writesFiles := toFiles || *statePath != "" || *lapsCSV != "" || *rangesCSV != "" || *sectorsCSV != "" || *diagnostics != ""
+	if writesFiles {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			fmt.Printf("error creating output directory: %v\n", err)
+			return 1
+		}
+	}
+	inOutputDir := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(*outputDir, path)
+	}
+
+	configFile := flags.Arg(0)
+	eventsFile := flags.Arg(1)
+
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return 1
+	}
+
+	run := newRaceRun(config)
+
+	if *follow {
+		var w io.Writer = os.Stdout
+		if !toStdout {
+			w = io.Discard
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := followEvents(ctx, eventsFile, run, config, w)
+		stop()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, logEntry := range run.finish() {
+			fmt.Fprintln(w, logEntry)
+		}
+		fmt.Fprintln(w)
+	} else {
+		events, err := readEvents(eventsFile, run)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, event := range events {
+			run.apply(event)
+		}
+		run.finish()
+
+		// Вывод выходного лога в консоль
+		if toStdout {
+			fmt.Println("Output Log")
+			for _, logEntry := range run.outputLog {
+				fmt.Println(logEntry)
+			}
+			fmt.Println("End Output Log")
+			fmt.Println() // Spacer
+		}
+	}
+
+	if *diagnostics != "" {
+		if err := writeDiagnostics(inOutputDir(*diagnostics), run.violations()); err != nil {
+			fmt.Printf("error writing diagnostics file: %v\n", err)
+			return 1
+		}
+	}
+
+	if *strict {
+		if problems := run.reportProblems(os.Stderr); problems > 0 {
+			fmt.Fprintf(os.Stderr, "strict mode: %d problem(s) in the events file, results not written\n", problems)
+			return 1
+		}
+	}
+
+	if toFiles {
+		// Сохраниение выходного лога в файл
+		outputLog := make([]string, 0, len(run.outputLog))
+		for _, logEntry := range run.outputLog {
+			outputLog = append(outputLog, logEntry.String())
+		}
+		if err := writeLines(inOutputDir(*outputLogPath), outputLog); err != nil {
+			fmt.Printf("error writing output log file: %v\n", err)
+			return 1
+		}
+
+		// Сохранение журнала входящих и исходящих событий в файл
+		if err := writeLines(inOutputDir(*eventsLogPath), run.eventLog); err != nil {
+			fmt.Printf("error writing output events file: %v\n", err)
+			return 1
+		}
+	}
+
+	if *statePath != "" {
+		if err := writeState(inOutputDir(*statePath), config, run.snapshot()); err != nil {
+			fmt.Printf("error writing state file: %v\n", err)
+			return 1
+		}
+	}
+
+	// Сохранение промежуточных результатов в CSV
+	if *lapsCSV != "" {
+		if err := writeCSV(inOutputDir(*lapsCSV), run.sortedSnapshot(), race.WriteLapsCSV); err != nil {
+			fmt.Printf("error writing laps CSV file: %v\n", err)
+			return 1
+		}
+	}
+	if *rangesCSV != "" {
+		if err := writeCSV(inOutputDir(*rangesCSV), run.sortedSnapshot(), race.WriteRangeVisitsCSV); err != nil {
+			fmt.Printf("error writing firing range CSV file: %v\n", err)
+			return 1
+		}
+	}
+	if *sectorsCSV != "" {
+		if err := writeCSV(inOutputDir(*sectorsCSV), run.sortedSnapshot(), race.WriteSectorsCSV); err != nil {
+			fmt.Printf("error writing sectors CSV file: %v\n", err)
+			return 1
+		}
+	}
+
+	// Вывод и сохранение в файл финального отчета
+	if *format == "json" {
+		var report bytes.Buffer
+		if err := writeJSONTable(&report, config, run.snapshot()); err != nil {
+			fmt.Printf("error encoding result table: %v\n", err)
+			return 1
+		}
+		if toStdout {
+			os.Stdout.Write(report.Bytes())
+		}
+		if toFiles {
+			if err := writeBytes(inOutputDir(*resultTablePath), report.Bytes()); err != nil {
+				fmt.Printf("error writing result table file: %v\n", err)
+				return 1
+			}
+		}
+		return 0
+	}
+
+	resultTable := run.standings(config)
+	if *progression {
+		resultTable = append(resultTable, progressionLines(config, run.snapshot())...)
+	}
+	if toStdout {
+		fmt.Println("Resulting Table")
+		for _, line := range resultTable {
+			fmt.Println(line)
+		}
+		fmt.Println("End Resulting Table")
+	}
+	if toFiles {
+		if err := writeLines(inOutputDir(*resultTablePath), resultTable); err != nil {
+			fmt.Printf("error writing result table file: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// sendCommand stands in for the timing equipment: it sends the lines of an
+// events file to a running ingest command and prints the reply to each.
+func sendCommand(args []string) int {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	network := flags.String("network", "tcp", "send events over tcp or udp")
+	addr := flags.String("addr", "localhost:9000", "send events to this `address`")
+	delay := flags.Duration("delay", 0, "wait this long between events")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . send [flags] <events>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+	if *network != "tcp" && *network != "udp" {
+		fmt.Printf("unknown network %q\n", *network)
+		flags.Usage()
+		return 1
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("error reading events file: %v\n", err)
+		return 1
+	}
+	conn, err := net.Dial(*network, *addr)
+	if err != nil {
+		fmt.Printf("error connecting to %s: %v\n", *addr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	if err := sendEvents(os.Stdout, conn, strings.Split(string(data), "\n"), *delay); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// replyTimeout bounds the wait for the reply to a sent line, so that a
+// lost UDP datagram does not hang the sender.
+var replyTimeout = 2 * time.Second
+
+// sendEvents writes each non-blank line to conn, waits for its reply and
+// prints both to w.
+func sendEvents(w io.Writer, conn net.Conn, lines []string, delay time.Duration) error {
+	replies := bufio.NewReader(conn)
+	sent := 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if sent > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		sent++
+
+		fmt.Fprintf(w, "> %s\n", line)
+		if _, err := fmt.Fprintln(conn, line); err != nil {
+			return fmt.Errorf("error sending event: %w", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(replyTimeout))
+		reply, err := replies.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error reading reply: %w", err)
+		}
+		fmt.Fprintf(w, "< %s", reply)
+	}
+	return nil
+}
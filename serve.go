@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"solid-system/race"
+)
+
+// serveCommand follows an events file like run --follow and serves the live
+// results as JSON over HTTP until interrupted.
+func serveCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "listen on this `address`")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . serve [flags] <config> <events>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 1
+	}
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+	run := newRaceRun(config)
+
+	server := &http.Server{Addr: *addr, Handler: newServer(run)}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("serving results on %s\n", *addr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- followEvents(ctx, flags.Arg(1), run, config, os.Stdout)
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		fmt.Printf("error serving results: %v\n", err)
+		exitCode = 1
+		stop()
+		<-followErr
+	case err := <-followErr:
+		if err != nil {
+			fmt.Println(err)
+			exitCode = 1
+		}
+	}
+	stop()
+
+	// Finishing first lets open event streams send the last entries and end.
+	for _, logEntry := range run.finish() {
+		fmt.Println(logEntry)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	return exitCode
+}
+
+// newServer returns the HTTP API over run:
+//
+//	GET /standings                the result table in report order
+//	GET /competitors/{id}         one competitor's report
+//	GET /competitors/{id}/laps    one competitor's laps
+//	GET /log?since=N              output log entries from index N on
+//	GET /events?since=N           the same entries as a server-sent event
+//	                              stream that stays open for new ones
+func newServer(run *raceRun) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /standings", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeJSONTable(w, run.config, run.snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("GET /competitors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if report, ok := competitorReport(w, r, run); ok {
+			writeJSON(w, report)
+		}
+	})
+
+	mux.HandleFunc("GET /competitors/{id}/laps", func(w http.ResponseWriter, r *http.Request) {
+		if report, ok := competitorReport(w, r, run); ok {
+			writeJSON(w, report.Laps)
+		}
+	})
+
+	mux.HandleFunc("GET /log", func(w http.ResponseWriter, r *http.Request) {
+		since, ok := logStart(w, r)
+		if !ok {
+			return
+		}
+		entries := []logMessage{}
+		for i, entry := range run.logSince(since) {
+			entries = append(entries, newLogMessage(since+i, entry))
+		}
+		writeJSON(w, entries)
+	})
+
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		since, ok := logStart(w, r)
+		if !ok {
+			return
+		}
+		streamLog(w, r, run, since)
+	})
+
+	return mux
+}
+
+// logStart returns the index of the first log entry a request asks for:
+// the one after its Last-Event-ID header when an event stream reconnects,
+// otherwise its since parameter. It writes a 400 response and returns
+// false if either is not a valid index.
+func logStart(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		last, err := strconv.Atoi(id)
+		if err != nil || last < 0 {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID %q", id), http.StatusBadRequest)
+			return 0, false
+		}
+		return last + 1, true
+	}
+	since := 0
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.Atoi(s); err != nil || since < 0 {
+			http.Error(w, fmt.Sprintf("invalid since %q", s), http.StatusBadRequest)
+			return 0, false
+		}
+	}
+	return since, true
+}
+
+// streamLog writes the output log from index since on to w as server-sent
+// events, one "log" event per entry with the entry's index as its ID, and
+// keeps writing new entries as they are applied. Once the run has finished
+// it sends an "end" event and returns.
+func streamLog(w http.ResponseWriter, r *http.Request, run *raceRun, since int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		entries, changed, finished := run.logWait(since)
+		for _, entry := range entries {
+			data, err := json.Marshal(newLogMessage(since, entry))
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", since, data); err != nil {
+				return
+			}
+			since++
+		}
+		if finished {
+			fmt.Fprint(w, "event: end\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// logMessage is the JSON form of an output log entry.
+type logMessage struct {
+	Index        int                   `json:"index"`
+	Time         string                `json:"time"`
+	Message      string                `json:"message"`
+	CompetitorID int                   `json:"competitorId"`
+	Status       race.CompetitorStatus `json:"status"`
+	// EventID is set for entries that generated an outgoing event.
+	EventID int `json:"eventId,omitempty"`
+}
+
+func newLogMessage(index int, entry race.LogEntry) logMessage {
+	message := logMessage{
+		Index:        index,
+		Time:         entry.Time.Format("15:04:05.000"),
+		Message:      entry.Message,
+		CompetitorID: entry.CompetitorID,
+		Status:       entry.Status,
+	}
+	if entry.Event != nil {
+		message.EventID = entry.Event.ID
+	}
+	return message
+}
+
+// competitorReport looks up the competitor named by the request's {id},
+// writing a 400 or 404 response and returning false if there is none.
+func competitorReport(w http.ResponseWriter, r *http.Request, run *raceRun) (race.CompetitorReport, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid competitor ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return race.CompetitorReport{}, false
+	}
+	competitor, ok := run.competitor(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("competitor %d not found", id), http.StatusNotFound)
+		return race.CompetitorReport{}, false
+	}
+	return race.NewCompetitorReport(run.config, &competitor), true
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
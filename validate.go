@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// validateCommand checks a config and an events file: it reports every
+// config problem, or every unparsable, out-of-order and rejected event,
+// without writing any results.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . validate <config> <events>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 1
+	}
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+
+	run := newRaceRun(config)
+	run.warnings = io.Discard
+	events, err := readEvents(flags.Arg(1), run)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, event := range events {
+		run.apply(event)
+	}
+	run.finish()
+
+	if problems := run.reportProblems(os.Stdout); problems > 0 {
+		fmt.Printf("%d problem(s) in %s\n", problems, flags.Arg(1))
+		return 1
+	}
+	fmt.Printf("%s and %s are valid: %d events\n", flags.Arg(0), flags.Arg(1), len(events))
+	return 0
+}